@@ -0,0 +1,1492 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decoder
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dongmx/rdb"
+	"github.com/dongmx/rdb/nopdecoder"
+)
+
+// An append only file is a rdb preamble followed by the write commands in
+// RESP. The commands are read first, and only the keys they touch are kept
+// in memory when the rdb part is decoded. The commands are read again and
+// replayed over these keys after the rdb part, then the keys are sent as
+// entries.
+//
+// Streams, module values and the commands writing them can not be replayed,
+// such keys are dropped if the commands touch them, and sent as they are in
+// the rdb part otherwise unless their database is flushed.
+
+// aofRDBVersion is the rdb version used to profile memory of an append only
+// file without rdb preamble
+const aofRDBVersion = 9
+
+// CommandFiles call read with each file of commands of an append only file
+// in order, positioned at the first command of the file
+type CommandFiles func(read func(r *bufio.Reader) error) error
+
+// AOF is the write commands of an append only file
+type AOF struct {
+	files CommandFiles
+	// keys touched by the commands
+	touched map[aofKey]bool
+	// databases flushed by the commands
+	flushed  map[int]bool
+	flushAll bool
+	// number of commands by name which are not replayed, the keys they
+	// write are dropped
+	dropped map[string]int
+	// number of unknown commands by name, the keys they write may be stale
+	unknown map[string]int
+}
+
+type aofKey struct {
+	DB  int
+	Key string
+}
+
+// NewAOF return an AOF of the commands of files, which are read twice: by
+// ReadCommands first, and when the commands are replayed
+func NewAOF(files CommandFiles) *AOF {
+	return &AOF{
+		files:   files,
+		touched: map[aofKey]bool{},
+		flushed: map[int]bool{},
+		dropped: map[string]int{},
+		unknown: map[string]int{},
+	}
+}
+
+// SkipRDB skip the rdb preamble of an append only file, r is at the first command then
+func SkipRDB(r *bufio.Reader) error {
+	// rdb.Decode reads from r itself as r is already buffered
+	if err := rdb.Decode(r, nopdecoder.NopDecoder{}); err != nil {
+		return err
+	}
+	// the checksum
+	_, err := r.Discard(8)
+	return err
+}
+
+// ReadCommands read the keys touched by the write commands of a file from r
+// until EOF, the commands of each file start at database 0.
+func (a *AOF) ReadCommands(r *bufio.Reader) error {
+	return readCommands(r, func(db int, name string, args [][]byte) {
+		switch name {
+		case "flushdb":
+			a.flushed[db] = true
+		case "flushall":
+			a.flushAll = true
+		case "multi", "exec":
+			return
+		}
+		cmd, ok := aofCommands[name]
+		if !ok {
+			a.unknown[name]++
+			return
+		}
+		if len(args) < cmd.arity {
+			return
+		}
+		keys := cmd.keys(db, args)
+		for _, k := range keys {
+			a.touched[k] = true
+		}
+		if cmd.apply == nil && len(keys) > 0 {
+			a.dropped[name]++
+		}
+	})
+}
+
+// readCommands call fn with each command of r and the database it is
+// applied to until EOF, the name of the command is in lower case
+func readCommands(r *bufio.Reader, fn func(db int, name string, args [][]byte)) error {
+	db := 0
+	for {
+		args, err := readRESPCommand(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			continue
+		}
+		args[0] = bytes.ToLower(args[0])
+		name := string(args[0])
+		if name == "select" && len(args) > 1 {
+			db, _ = strconv.Atoi(string(args[1]))
+		}
+		fn(db, name, args)
+	}
+}
+
+// readRESPCommand read a command sent as array of bulk strings, lines of
+// annotations such as "#TS:..." are skipped
+func readRESPCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readRESPLine(r)
+	if err != nil {
+		return nil, err
+	}
+	for len(line) > 0 && line[0] == '#' {
+		if line, err = readRESPLine(r); err != nil {
+			return nil, err
+		}
+	}
+	if len(line) < 2 || line[0] != '*' {
+		return nil, fmt.Errorf("unexpected RESP line %q", line)
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readRESPLine(r)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		if len(line) < 2 || line[0] != '$' {
+			return nil, fmt.Errorf("unexpected RESP line %q", line)
+		}
+		l, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, l+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, noEOF(err)
+		}
+		args = append(args, arg[:l])
+	}
+	return args, nil
+}
+
+func readRESPLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// noEOF turn EOF within a command into ErrUnexpectedEOF
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Decoder return a rdb.Decoder which sends the rdb part to d except the
+// keys touched by the commands, which are replayed and sent when the rdb
+// part ends. Errors of the rdb part must be added to its AddError method.
+func (a *AOF) Decoder(d *Decoder) rdb.Decoder {
+	return &aofDecoder{
+		Decoder: d,
+		aof:     a,
+		keys:    map[aofKey]*aofValue{},
+	}
+}
+
+// Replay the commands of an append only file without rdb preamble and send
+// the keys to d
+func (a *AOF) Replay(d *Decoder) {
+	ad := a.Decoder(d)
+	ad.StartRDB(aofRDBVersion)
+	ad.EndRDB()
+}
+
+const (
+	aofForward = iota
+	aofCapture
+	aofDrop
+)
+
+// aofDecoder captures the keys touched by the commands of an AOF
+type aofDecoder struct {
+	*Decoder
+	aof  *AOF
+	keys map[aofKey]*aofValue
+	db   int
+	// number of streams and module values dropped as the commands touch them
+	droppedValues int
+
+	// what to do with the current key of the rdb part
+	mode  int
+	key   aofKey
+	value *aofValue
+}
+
+// aofValue is a value of a key touched by the commands
+type aofValue struct {
+	typ    string
+	str    []byte
+	hash   map[string][]byte
+	set    map[string]bool
+	list   [][]byte
+	zset   map[string]float64
+	expiry int64
+	idle   int64
+	freq   int
+}
+
+func (ad *aofDecoder) StartDatabase(n int) {
+	ad.db = n
+	ad.Decoder.StartDatabase(n)
+}
+
+// start decide what to do with key of the rdb part
+func (ad *aofDecoder) start(key []byte, typ string, expiry int64, info *rdb.Info) {
+	k := aofKey{DB: ad.db, Key: string(key)}
+	ad.key = k
+	ad.value = nil
+	switch {
+	case ad.aof.touched[k] && typ == "":
+		ad.mode = aofDrop
+		ad.droppedValues++
+	case ad.aof.touched[k]:
+		ad.mode = aofCapture
+		ad.value = newAOFValue(typ)
+		ad.value.expiry = expiry
+		ad.value.idle = info.Idle
+		ad.value.freq = info.Freq
+		ad.keys[k] = ad.value
+	case ad.aof.flushAll || ad.aof.flushed[ad.db]:
+		ad.mode = aofDrop
+	default:
+		ad.mode = aofForward
+	}
+}
+
+// AddError drops the key being captured, its value is partial
+func (ad *aofDecoder) AddError(err error) {
+	if ad.mode == aofCapture {
+		delete(ad.keys, ad.key)
+		ad.mode = aofDrop
+	}
+	ad.Decoder.AddError(err)
+}
+
+func newAOFValue(typ string) *aofValue {
+	v := &aofValue{typ: typ, idle: -1, freq: -1}
+	switch typ {
+	case "hash":
+		v.hash = map[string][]byte{}
+	case "set":
+		v.set = map[string]bool{}
+	case "zset":
+		v.zset = map[string]float64{}
+	}
+	return v
+}
+
+func (ad *aofDecoder) Set(key, value []byte, expiry int64, info *rdb.Info) {
+	ad.start(key, "string", expiry, info)
+	switch ad.mode {
+	case aofCapture:
+		ad.value.str = append([]byte{}, value...)
+	case aofForward:
+		ad.Decoder.Set(key, value, expiry, info)
+	}
+}
+
+func (ad *aofDecoder) StartHash(key []byte, length, expiry int64, info *rdb.Info) {
+	ad.start(key, "hash", expiry, info)
+	if ad.mode == aofForward {
+		ad.Decoder.StartHash(key, length, expiry, info)
+	}
+}
+
+func (ad *aofDecoder) Hset(key, field, value []byte) {
+	switch ad.mode {
+	case aofCapture:
+		ad.value.hash[string(field)] = append([]byte{}, value...)
+	case aofForward:
+		ad.Decoder.Hset(key, field, value)
+	}
+}
+
+func (ad *aofDecoder) EndHash(key []byte) {
+	if ad.mode == aofForward {
+		ad.Decoder.EndHash(key)
+	}
+}
+
+func (ad *aofDecoder) StartSet(key []byte, cardinality, expiry int64, info *rdb.Info) {
+	ad.start(key, "set", expiry, info)
+	if ad.mode == aofForward {
+		ad.Decoder.StartSet(key, cardinality, expiry, info)
+	}
+}
+
+func (ad *aofDecoder) Sadd(key, member []byte) {
+	switch ad.mode {
+	case aofCapture:
+		ad.value.set[string(member)] = true
+	case aofForward:
+		ad.Decoder.Sadd(key, member)
+	}
+}
+
+func (ad *aofDecoder) EndSet(key []byte) {
+	if ad.mode == aofForward {
+		ad.Decoder.EndSet(key)
+	}
+}
+
+func (ad *aofDecoder) StartList(key []byte, length, expiry int64, info *rdb.Info) {
+	ad.start(key, "list", expiry, info)
+	if ad.mode == aofForward {
+		ad.Decoder.StartList(key, length, expiry, info)
+	}
+}
+
+func (ad *aofDecoder) Rpush(key, value []byte) {
+	switch ad.mode {
+	case aofCapture:
+		ad.value.list = append(ad.value.list, append([]byte{}, value...))
+	case aofForward:
+		ad.Decoder.Rpush(key, value)
+	}
+}
+
+func (ad *aofDecoder) EndList(key []byte) {
+	if ad.mode == aofForward {
+		ad.Decoder.EndList(key)
+	}
+}
+
+func (ad *aofDecoder) StartZSet(key []byte, cardinality, expiry int64, info *rdb.Info) {
+	ad.start(key, "zset", expiry, info)
+	if ad.mode == aofForward {
+		ad.Decoder.StartZSet(key, cardinality, expiry, info)
+	}
+}
+
+func (ad *aofDecoder) Zadd(key []byte, score float64, member []byte) {
+	switch ad.mode {
+	case aofCapture:
+		ad.value.zset[string(member)] = score
+	case aofForward:
+		ad.Decoder.Zadd(key, score, member)
+	}
+}
+
+func (ad *aofDecoder) EndZSet(key []byte) {
+	if ad.mode == aofForward {
+		ad.Decoder.EndZSet(key)
+	}
+}
+
+func (ad *aofDecoder) StartStream(key []byte, cardinality, expiry int64, info *rdb.Info) {
+	ad.start(key, "", expiry, info)
+	if ad.mode == aofForward {
+		ad.Decoder.StartStream(key, cardinality, expiry, info)
+	}
+}
+
+func (ad *aofDecoder) Xadd(key, id, listpack []byte) {
+	if ad.mode == aofForward {
+		ad.Decoder.Xadd(key, id, listpack)
+	}
+}
+
+func (ad *aofDecoder) EndStream(key []byte, items uint64, lastEntryID string, cgroupsData rdb.StreamGroups) {
+	if ad.mode == aofForward {
+		ad.Decoder.EndStream(key, items, lastEntryID, cgroupsData)
+	}
+}
+
+func (ad *aofDecoder) Module(key []byte, moduleName string, expiry int64, info *rdb.Info) {
+	ad.start(key, "", expiry, info)
+	if ad.mode == aofForward {
+		ad.Decoder.Module(key, moduleName, expiry, info)
+	}
+}
+
+// EndRDB replay the commands and send the keys touched by them
+func (ad *aofDecoder) EndRDB() {
+	r := &aofReplay{keys: ad.keys, now: ad.Decoder.ctime * 1000}
+	err := ad.aof.files(func(f *bufio.Reader) error {
+		err := readCommands(f, func(db int, name string, args [][]byte) {
+			cmd, ok := aofCommands[name]
+			if !ok || len(args) < cmd.arity {
+				return
+			}
+			r.db = db
+			if cmd.apply == nil {
+				for _, k := range cmd.keys(db, args) {
+					delete(r.keys, k)
+				}
+				return
+			}
+			cmd.apply(r, args)
+		})
+		// the truncated command is ignored as when the keys were read
+		if err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		ad.Decoder.AddError(fmt.Errorf("replay append only file: %v", err))
+	}
+	ad.addCommandErrors()
+
+	keys := make([]aofKey, 0, len(ad.keys))
+	for k := range ad.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].DB != keys[j].DB {
+			return keys[i].DB < keys[j].DB
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	db := -1
+	for _, k := range keys {
+		if k.DB != db {
+			db = k.DB
+			ad.Decoder.StartDatabase(db)
+		}
+		ad.send([]byte(k.Key), ad.keys[k])
+	}
+	ad.Decoder.EndRDB()
+}
+
+// addCommandErrors report the commands which are not replayed
+func (ad *aofDecoder) addCommandErrors() {
+	if ad.droppedValues > 0 {
+		ad.Decoder.AddError(fmt.Errorf("%d streams or module values of the append only file are dropped as the commands touch them", ad.droppedValues))
+	}
+	for _, name := range sortedCounts(ad.aof.dropped) {
+		ad.Decoder.AddError(fmt.Errorf("%d %s commands of the append only file can not be replayed, the keys written by them are dropped",
+			ad.aof.dropped[name], strings.ToUpper(name)))
+	}
+	for _, name := range sortedCounts(ad.aof.unknown) {
+		ad.Decoder.AddError(fmt.Errorf("%d %s commands of the append only file are unknown, the keys written by them may be stale",
+			ad.aof.unknown[name], strings.ToUpper(name)))
+	}
+}
+
+func sortedCounts(counts map[string]int) []string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// send v to the decoder in the encoding redis would choose with the
+// thresholds of the redis config of what-if estimates, or the defaults
+func (ad *aofDecoder) send(key []byte, v *aofValue) {
+	d := ad.Decoder
+	conf := d.GetRedisConfig()
+	if conf == nil {
+		conf = DefaultRedisConfig()
+	}
+	compact := d.compactEncoding()
+	info := &rdb.Info{Idle: v.idle, Freq: v.freq}
+	switch v.typ {
+	case "string":
+		info.Encoding = "string"
+		d.Set(key, v.str, v.expiry, info)
+
+	case "hash":
+		fields := sortedKeys(v.hash)
+		info.Encoding = "hashtable"
+		if int64(len(fields)) <= conf.HashMaxListpackEntries {
+			size := d.compactHeader()
+			for _, f := range fields {
+				size += d.compactEntry([]byte(f)) + d.compactEntry(v.hash[f])
+				if int64(len(f)) > conf.HashMaxListpackValue || int64(len(v.hash[f])) > conf.HashMaxListpackValue {
+					size = 0
+					break
+				}
+			}
+			if size > 0 {
+				info.Encoding = compact
+				info.SizeOfValue = int(size)
+			}
+		}
+		d.StartHash(key, int64(len(fields)), v.expiry, info)
+		for _, f := range fields {
+			d.Hset(key, []byte(f), v.hash[f])
+		}
+		d.EndHash(key)
+
+	case "set":
+		members := make([]string, 0, len(v.set))
+		for m := range v.set {
+			members = append(members, m)
+		}
+		sort.Strings(members)
+		info.Encoding = "hashtable"
+		if width, ok := intsetWidth(members); ok && int64(len(members)) <= conf.SetMaxIntsetEntries {
+			info.Encoding = "intset"
+			// encoding + length + contents
+			info.SizeOfValue = 4 + 4 + len(members)*int(width)
+		} else if d.setListpack() && int64(len(members)) <= conf.SetMaxListpackEntries {
+			size := d.compactHeader()
+			for _, m := range members {
+				size += d.compactEntry([]byte(m))
+				if int64(len(m)) > conf.SetMaxListpackValue {
+					size = 0
+					break
+				}
+			}
+			if size > 0 {
+				info.Encoding = "listpack"
+				info.SizeOfValue = int(size)
+			}
+		}
+		d.StartSet(key, int64(len(members)), v.expiry, info)
+		for _, m := range members {
+			d.Sadd(key, []byte(m))
+		}
+		d.EndSet(key)
+
+	case "list":
+		size := uint64(0)
+		for _, e := range v.list {
+			size += d.compactEntry(e)
+		}
+		info.Encoding = "quicklist"
+		info.NodeEncoding = compact
+		info.Zips = quicklistNodes(uint64(len(v.list)), size, conf.ListMaxListpackSize)
+		d.StartList(key, int64(len(v.list)), v.expiry, info)
+		for _, e := range v.list {
+			d.Rpush(key, e)
+		}
+		d.EndList(key)
+
+	case "zset":
+		members := sortedZSet(v.zset)
+		info.Encoding = "skiplist"
+		if int64(len(members)) <= conf.ZsetMaxListpackEntries {
+			size := d.compactHeader()
+			for _, m := range members {
+				size += d.compactEntry([]byte(m)) + d.compactEntry([]byte(formatScore(v.zset[m])))
+				if int64(len(m)) > conf.ZsetMaxListpackValue {
+					size = 0
+					break
+				}
+			}
+			if size > 0 {
+				info.Encoding = compact
+				info.SizeOfValue = int(size)
+			}
+		}
+		d.StartZSet(key, int64(len(members)), v.expiry, info)
+		for _, m := range members {
+			d.Zadd(key, v.zset[m], []byte(m))
+		}
+		d.EndZSet(key)
+	}
+}
+
+// intsetWidth get the bytes of each integer of an intset of the members, ok
+// is false if they are not all integers
+func intsetWidth(members []string) (width uint64, ok bool) {
+	for _, m := range members {
+		num, ok := redisInt([]byte(m))
+		if !ok {
+			return 0, false
+		}
+		if w := intWidth(num); w > width {
+			width = w
+		}
+	}
+	return width, true
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedZSet return the members of a sorted set in order
+func sortedZSet(zset map[string]float64) []string {
+	members := make([]string, 0, len(zset))
+	for m := range zset {
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		a, b := zset[members[i]], zset[members[j]]
+		if a != b {
+			return a < b
+		}
+		return members[i] < members[j]
+	})
+	return members
+}
+
+// aofReplay is the state of replaying commands
+type aofReplay struct {
+	keys map[aofKey]*aofValue
+	db   int
+	// number of streams and module values dropped as the commands touch them
+	droppedValues int
+	// now is the unix time in milliseconds relative expire times are added to
+	now int64
+}
+
+func (r *aofReplay) key(k []byte) aofKey {
+	return aofKey{DB: r.db, Key: string(k)}
+}
+
+// get the value of key if it is typ, nil otherwise
+func (r *aofReplay) get(key []byte, typ string) *aofValue {
+	v := r.keys[r.key(key)]
+	if v == nil || v.typ != typ {
+		return nil
+	}
+	return v
+}
+
+// getOrCreate the value of key, nil if it is not typ
+func (r *aofReplay) getOrCreate(key []byte, typ string) *aofValue {
+	k := r.key(key)
+	v, ok := r.keys[k]
+	if !ok {
+		v = newAOFValue(typ)
+		r.keys[k] = v
+	}
+	if v.typ != typ {
+		return nil
+	}
+	return v
+}
+
+// delIfEmpty delete key if its value has no elements
+func (r *aofReplay) delIfEmpty(key []byte, v *aofValue) {
+	if len(v.hash)+len(v.set)+len(v.list)+len(v.zset) == 0 {
+		delete(r.keys, r.key(key))
+	}
+}
+
+func (r *aofReplay) setString(key, value []byte, expiry int64) {
+	v := newAOFValue("string")
+	v.str = append([]byte{}, value...)
+	v.expiry = expiry
+	r.keys[r.key(key)] = v
+}
+
+// aofCommandSpec is how a write command is replayed
+type aofCommandSpec struct {
+	// minimal number of arguments including the command name
+	arity int
+	// keys return the keys touched
+	keys func(db int, args [][]byte) []aofKey
+	// apply is nil for commands which can not be replayed, the keys they
+	// touch are dropped
+	apply func(r *aofReplay, args [][]byte)
+}
+
+// firstKey is the keys of commands touching args[1] only
+func firstKey(db int, args [][]byte) []aofKey {
+	return []aofKey{{DB: db, Key: string(args[1])}}
+}
+
+// allKeys is the keys of commands touching all arguments
+func allKeys(db int, args [][]byte) []aofKey {
+	keys := []aofKey{}
+	for _, a := range args[1:] {
+		keys = append(keys, aofKey{DB: db, Key: string(a)})
+	}
+	return keys
+}
+
+// twoKeys is the keys of commands touching args[1] and args[2]
+func twoKeys(db int, args [][]byte) []aofKey {
+	return []aofKey{{DB: db, Key: string(args[1])}, {DB: db, Key: string(args[2])}}
+}
+
+// keyAt is the keys of commands touching args[i] only
+func keyAt(i int) func(db int, args [][]byte) []aofKey {
+	return func(db int, args [][]byte) []aofKey {
+		return []aofKey{{DB: db, Key: string(args[i])}}
+	}
+}
+
+// sortStoreKeys is the keys of SORT ... STORE destination
+func sortStoreKeys(db int, args [][]byte) []aofKey {
+	for i := 2; i < len(args)-1; i++ {
+		if strings.EqualFold(string(args[i]), "store") {
+			return []aofKey{{DB: db, Key: string(args[i+1])}}
+		}
+	}
+	return nil
+}
+
+// noKeys is the keys of commands touching whole databases
+func noKeys(db int, args [][]byte) []aofKey {
+	return nil
+}
+
+var aofCommands map[string]aofCommandSpec
+
+func init() {
+	aofCommands = map[string]aofCommandSpec{
+		"select":   {2, noKeys, func(r *aofReplay, args [][]byte) {}},
+		"flushdb":  {1, noKeys, replayFlushDB},
+		"flushall": {1, noKeys, replayFlushAll},
+		"del":      {2, allKeys, replayDel},
+		"unlink":   {2, allKeys, replayDel},
+		"getdel":   {2, firstKey, replayDel},
+		"rename":   {3, twoKeys, replayRename},
+		"renamenx": {3, twoKeys, replayRename},
+		"move":     {3, moveKeys, replayMove},
+		"copy":     {3, copyKeys, replayCopy},
+
+		"expire":    {3, firstKey, replayExpire},
+		"pexpire":   {3, firstKey, replayExpire},
+		"expireat":  {3, firstKey, replayExpire},
+		"pexpireat": {3, firstKey, replayExpire},
+		"persist":   {2, firstKey, replayPersist},
+
+		"set":         {3, firstKey, replaySet},
+		"setnx":       {3, firstKey, replaySet},
+		"setex":       {4, firstKey, replaySet},
+		"psetex":      {4, firstKey, replaySet},
+		"getset":      {3, firstKey, replaySet},
+		"mset":        {3, msetKeys, replayMSet},
+		"msetnx":      {3, msetKeys, replayMSet},
+		"append":      {3, firstKey, replayAppend},
+		"setrange":    {4, firstKey, replaySetRange},
+		"incr":        {2, firstKey, replayIncr},
+		"decr":        {2, firstKey, replayIncr},
+		"incrby":      {3, firstKey, replayIncr},
+		"decrby":      {3, firstKey, replayIncr},
+		"incrbyfloat": {3, firstKey, replayIncr},
+
+		"hset":         {4, firstKey, replayHSet},
+		"hmset":        {4, firstKey, replayHSet},
+		"hsetnx":       {4, firstKey, replayHSet},
+		"hdel":         {3, firstKey, replayHDel},
+		"hincrby":      {4, firstKey, replayHIncr},
+		"hincrbyfloat": {4, firstKey, replayHIncr},
+
+		"sadd":  {3, firstKey, replaySAdd},
+		"srem":  {3, firstKey, replaySRem},
+		"smove": {4, twoKeys, replaySMove},
+
+		"rpush":     {3, firstKey, replayPush},
+		"lpush":     {3, firstKey, replayPush},
+		"rpushx":    {3, firstKey, replayPush},
+		"lpushx":    {3, firstKey, replayPush},
+		"lpop":      {2, firstKey, replayPop},
+		"rpop":      {2, firstKey, replayPop},
+		"lset":      {4, firstKey, replayLSet},
+		"ltrim":     {4, firstKey, replayLTrim},
+		"lrem":      {4, firstKey, replayLRem},
+		"linsert":   {5, firstKey, replayLInsert},
+		"rpoplpush": {3, twoKeys, replayLMove},
+		"lmove":     {5, twoKeys, replayLMove},
+
+		"zadd":             {4, firstKey, replayZAdd},
+		"zincrby":          {4, firstKey, replayZIncrBy},
+		"zrem":             {3, firstKey, replayZRem},
+		"zpopmin":          {2, firstKey, replayZPop},
+		"zpopmax":          {2, firstKey, replayZPop},
+		"zremrangebyrank":  {4, firstKey, replayZRemRangeByRank},
+		"zremrangebyscore": {4, firstKey, replayZRemRangeByScore},
+
+		"xadd":           {5, firstKey, nil},
+		"xtrim":          {4, firstKey, nil},
+		"xdel":           {3, firstKey, nil},
+		"xsetid":         {3, firstKey, nil},
+		"xack":           {4, firstKey, nil},
+		"xclaim":         {6, firstKey, nil},
+		"xautoclaim":     {6, firstKey, nil},
+		"xgroup":         {3, keyAt(2), nil},
+		"setbit":         {4, firstKey, nil},
+		"bitfield":       {2, firstKey, nil},
+		"bitop":          {4, keyAt(2), nil},
+		"pfadd":          {2, firstKey, nil},
+		"pfmerge":        {2, firstKey, nil},
+		"sunionstore":    {3, firstKey, nil},
+		"sinterstore":    {3, firstKey, nil},
+		"sdiffstore":     {3, firstKey, nil},
+		"zunionstore":    {4, firstKey, nil},
+		"zinterstore":    {4, firstKey, nil},
+		"zdiffstore":     {4, firstKey, nil},
+		"zrangestore":    {5, firstKey, nil},
+		"geoadd":         {5, firstKey, nil},
+		"geosearchstore": {5, firstKey, nil},
+		"sort":           {2, sortStoreKeys, nil},
+		"restore":        {4, firstKey, nil},
+	}
+}
+
+func replayFlushDB(r *aofReplay, args [][]byte) {
+	for k := range r.keys {
+		if k.DB == r.db {
+			delete(r.keys, k)
+		}
+	}
+}
+
+func replayFlushAll(r *aofReplay, args [][]byte) {
+	for k := range r.keys {
+		delete(r.keys, k)
+	}
+}
+
+func replayDel(r *aofReplay, args [][]byte) {
+	for _, key := range args[1:] {
+		delete(r.keys, r.key(key))
+	}
+}
+
+func replayRename(r *aofReplay, args [][]byte) {
+	src, dst := r.key(args[1]), r.key(args[2])
+	v, ok := r.keys[src]
+	if !ok {
+		return
+	}
+	if _, exists := r.keys[dst]; exists && string(args[0]) == "renamenx" {
+		return
+	}
+	delete(r.keys, src)
+	r.keys[dst] = v
+}
+
+func moveKeys(db int, args [][]byte) []aofKey {
+	keys := firstKey(db, args)
+	if to, err := strconv.Atoi(string(args[2])); err == nil {
+		keys = append(keys, aofKey{DB: to, Key: string(args[1])})
+	}
+	return keys
+}
+
+func replayMove(r *aofReplay, args [][]byte) {
+	to, err := strconv.Atoi(string(args[2]))
+	if err != nil {
+		return
+	}
+	src := r.key(args[1])
+	dst := aofKey{DB: to, Key: src.Key}
+	v, ok := r.keys[src]
+	if _, exists := r.keys[dst]; !ok || exists {
+		return
+	}
+	delete(r.keys, src)
+	r.keys[dst] = v
+}
+
+// copyDB return the destination database of COPY
+func copyDB(db int, args [][]byte) (int, bool) {
+	replace := false
+	for i := 3; i < len(args); i++ {
+		switch strings.ToLower(string(args[i])) {
+		case "db":
+			if i+1 < len(args) {
+				db, _ = strconv.Atoi(string(args[i+1]))
+				i++
+			}
+		case "replace":
+			replace = true
+		}
+	}
+	return db, replace
+}
+
+func copyKeys(db int, args [][]byte) []aofKey {
+	to, _ := copyDB(db, args)
+	return []aofKey{{DB: db, Key: string(args[1])}, {DB: to, Key: string(args[2])}}
+}
+
+func replayCopy(r *aofReplay, args [][]byte) {
+	to, replace := copyDB(r.db, args)
+	v, ok := r.keys[r.key(args[1])]
+	dst := aofKey{DB: to, Key: string(args[2])}
+	if _, exists := r.keys[dst]; !ok || (exists && !replace) {
+		return
+	}
+	c := *v
+	c.hash, c.set, c.zset = nil, nil, nil
+	c.list = append([][]byte{}, v.list...)
+	if v.hash != nil {
+		c.hash = map[string][]byte{}
+		for f, val := range v.hash {
+			c.hash[f] = val
+		}
+	}
+	if v.set != nil {
+		c.set = map[string]bool{}
+		for m := range v.set {
+			c.set[m] = true
+		}
+	}
+	if v.zset != nil {
+		c.zset = map[string]float64{}
+		for m, s := range v.zset {
+			c.zset[m] = s
+		}
+	}
+	r.keys[dst] = &c
+}
+
+// expireAt return the absolute expire time in milliseconds of t in unit of cmd
+func (r *aofReplay) expireAt(cmd string, t []byte) (int64, bool) {
+	n, err := strconv.ParseInt(string(t), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	switch cmd {
+	case "expire", "setex", "ex":
+		return r.now + n*1000, true
+	case "pexpire", "psetex", "px":
+		return r.now + n, true
+	case "expireat", "exat":
+		return n * 1000, true
+	}
+	return n, true
+}
+
+func replayExpire(r *aofReplay, args [][]byte) {
+	v := r.keys[r.key(args[1])]
+	at, ok := r.expireAt(string(args[0]), args[2])
+	if v == nil || !ok {
+		return
+	}
+	if len(args) > 3 {
+		switch strings.ToLower(string(args[3])) {
+		case "nx":
+			ok = v.expiry == 0
+		case "xx":
+			ok = v.expiry != 0
+		case "gt":
+			ok = v.expiry != 0 && at > v.expiry
+		case "lt":
+			ok = v.expiry == 0 || at < v.expiry
+		}
+	}
+	if ok {
+		v.expiry = at
+	}
+}
+
+func replayPersist(r *aofReplay, args [][]byte) {
+	if v := r.keys[r.key(args[1])]; v != nil {
+		v.expiry = 0
+	}
+}
+
+func replaySet(r *aofReplay, args [][]byte) {
+	cmd := string(args[0])
+	key, value := args[1], args[2]
+	_, exists := r.keys[r.key(key)]
+	var expiry int64
+	switch cmd {
+	case "setnx":
+		if exists {
+			return
+		}
+	case "setex", "psetex":
+		at, ok := r.expireAt(cmd, args[2])
+		if !ok {
+			return
+		}
+		expiry, value = at, args[3]
+	case "set":
+		for i := 3; i < len(args); i++ {
+			opt := strings.ToLower(string(args[i]))
+			switch opt {
+			case "nx":
+				if exists {
+					return
+				}
+			case "xx":
+				if !exists {
+					return
+				}
+			case "keepttl":
+				if v := r.keys[r.key(key)]; v != nil {
+					expiry = v.expiry
+				}
+			case "ex", "px", "exat", "pxat":
+				if i+1 < len(args) {
+					expiry, _ = r.expireAt(opt, args[i+1])
+					i++
+				}
+			}
+		}
+	}
+	r.setString(key, value, expiry)
+}
+
+func msetKeys(db int, args [][]byte) []aofKey {
+	keys := []aofKey{}
+	for i := 1; i+1 < len(args); i += 2 {
+		keys = append(keys, aofKey{DB: db, Key: string(args[i])})
+	}
+	return keys
+}
+
+func replayMSet(r *aofReplay, args [][]byte) {
+	if string(args[0]) == "msetnx" {
+		for i := 1; i+1 < len(args); i += 2 {
+			if _, exists := r.keys[r.key(args[i])]; exists {
+				return
+			}
+		}
+	}
+	for i := 1; i+1 < len(args); i += 2 {
+		r.setString(args[i], args[i+1], 0)
+	}
+}
+
+func replayAppend(r *aofReplay, args [][]byte) {
+	if v := r.getOrCreate(args[1], "string"); v != nil {
+		v.str = append(v.str, args[2]...)
+	}
+}
+
+func replaySetRange(r *aofReplay, args [][]byte) {
+	offset, err := strconv.Atoi(string(args[2]))
+	// an empty value does not create the key
+	if err != nil || offset < 0 || len(args[3]) == 0 {
+		return
+	}
+	v := r.getOrCreate(args[1], "string")
+	if v == nil {
+		return
+	}
+	if end := offset + len(args[3]); end > len(v.str) {
+		v.str = append(v.str, make([]byte, end-len(v.str))...)
+	}
+	copy(v.str[offset:], args[3])
+}
+
+func replayIncr(r *aofReplay, args [][]byte) {
+	v := r.getOrCreate(args[1], "string")
+	if v == nil {
+		return
+	}
+	cmd := string(args[0])
+	if cmd == "incrbyfloat" {
+		cur, _ := strconv.ParseFloat(string(v.str), 64)
+		inc, _ := strconv.ParseFloat(string(args[2]), 64)
+		v.str = []byte(strconv.FormatFloat(cur+inc, 'f', -1, 64))
+		return
+	}
+	cur, _ := strconv.ParseInt(string(v.str), 10, 64)
+	inc := int64(1)
+	if len(args) > 2 {
+		inc, _ = strconv.ParseInt(string(args[2]), 10, 64)
+	}
+	if strings.HasPrefix(cmd, "decr") {
+		inc = -inc
+	}
+	v.str = []byte(strconv.FormatInt(cur+inc, 10))
+}
+
+func replayHSet(r *aofReplay, args [][]byte) {
+	v := r.getOrCreate(args[1], "hash")
+	if v == nil {
+		return
+	}
+	nx := string(args[0]) == "hsetnx"
+	for i := 2; i+1 < len(args); i += 2 {
+		if _, exists := v.hash[string(args[i])]; exists && nx {
+			continue
+		}
+		v.hash[string(args[i])] = args[i+1]
+	}
+}
+
+func replayHDel(r *aofReplay, args [][]byte) {
+	v := r.get(args[1], "hash")
+	if v == nil {
+		return
+	}
+	for _, f := range args[2:] {
+		delete(v.hash, string(f))
+	}
+	r.delIfEmpty(args[1], v)
+}
+
+func replayHIncr(r *aofReplay, args [][]byte) {
+	v := r.getOrCreate(args[1], "hash")
+	if v == nil {
+		return
+	}
+	field := string(args[2])
+	if string(args[0]) == "hincrbyfloat" {
+		cur, _ := strconv.ParseFloat(string(v.hash[field]), 64)
+		inc, _ := strconv.ParseFloat(string(args[3]), 64)
+		v.hash[field] = []byte(strconv.FormatFloat(cur+inc, 'f', -1, 64))
+		return
+	}
+	cur, _ := strconv.ParseInt(string(v.hash[field]), 10, 64)
+	inc, _ := strconv.ParseInt(string(args[3]), 10, 64)
+	v.hash[field] = []byte(strconv.FormatInt(cur+inc, 10))
+}
+
+func replaySAdd(r *aofReplay, args [][]byte) {
+	if v := r.getOrCreate(args[1], "set"); v != nil {
+		for _, m := range args[2:] {
+			v.set[string(m)] = true
+		}
+	}
+}
+
+func replaySRem(r *aofReplay, args [][]byte) {
+	v := r.get(args[1], "set")
+	if v == nil {
+		return
+	}
+	for _, m := range args[2:] {
+		delete(v.set, string(m))
+	}
+	r.delIfEmpty(args[1], v)
+}
+
+func replaySMove(r *aofReplay, args [][]byte) {
+	src := r.get(args[1], "set")
+	if src == nil || !src.set[string(args[3])] {
+		return
+	}
+	dst := r.getOrCreate(args[2], "set")
+	if dst == nil {
+		return
+	}
+	delete(src.set, string(args[3]))
+	dst.set[string(args[3])] = true
+	r.delIfEmpty(args[1], src)
+}
+
+func replayPush(r *aofReplay, args [][]byte) {
+	cmd := string(args[0])
+	var v *aofValue
+	if strings.HasSuffix(cmd, "x") {
+		v = r.get(args[1], "list")
+	} else {
+		v = r.getOrCreate(args[1], "list")
+	}
+	if v == nil {
+		return
+	}
+	for _, e := range args[2:] {
+		if cmd[0] == 'l' {
+			v.list = append([][]byte{e}, v.list...)
+		} else {
+			v.list = append(v.list, e)
+		}
+	}
+}
+
+func replayPop(r *aofReplay, args [][]byte) {
+	v := r.get(args[1], "list")
+	if v == nil {
+		return
+	}
+	n := 1
+	if len(args) > 2 {
+		n, _ = strconv.Atoi(string(args[2]))
+	}
+	if n > len(v.list) {
+		n = len(v.list)
+	}
+	if args[0][0] == 'l' {
+		v.list = v.list[n:]
+	} else {
+		v.list = v.list[:len(v.list)-n]
+	}
+	r.delIfEmpty(args[1], v)
+}
+
+// listIndex return the index of i in a list of length n, -1 if out of range
+func listIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < 0 || i >= n {
+		return -1
+	}
+	return i
+}
+
+func replayLSet(r *aofReplay, args [][]byte) {
+	v := r.get(args[1], "list")
+	i, err := strconv.Atoi(string(args[2]))
+	if v == nil || err != nil {
+		return
+	}
+	if i = listIndex(i, len(v.list)); i >= 0 {
+		v.list[i] = args[3]
+	}
+}
+
+func replayLTrim(r *aofReplay, args [][]byte) {
+	v := r.get(args[1], "list")
+	if v == nil {
+		return
+	}
+	start, _ := strconv.Atoi(string(args[2]))
+	stop, _ := strconv.Atoi(string(args[3]))
+	n := len(v.list)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		v.list = nil
+	} else {
+		v.list = v.list[start : stop+1]
+	}
+	r.delIfEmpty(args[1], v)
+}
+
+func replayLRem(r *aofReplay, args [][]byte) {
+	v := r.get(args[1], "list")
+	count, err := strconv.Atoi(string(args[2]))
+	if v == nil || err != nil {
+		return
+	}
+	// a negative count removes from tail to head
+	list := make([][]byte, 0, len(v.list))
+	removed := 0
+	for i := range v.list {
+		e := v.list[i]
+		if count < 0 {
+			e = v.list[len(v.list)-1-i]
+		}
+		if bytes.Equal(e, args[3]) && (count == 0 || removed < count || removed < -count) {
+			removed++
+			continue
+		}
+		list = append(list, e)
+	}
+	if count < 0 {
+		for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+			list[i], list[j] = list[j], list[i]
+		}
+	}
+	v.list = list
+	r.delIfEmpty(args[1], v)
+}
+
+func replayLInsert(r *aofReplay, args [][]byte) {
+	v := r.get(args[1], "list")
+	if v == nil {
+		return
+	}
+	after := strings.ToLower(string(args[2])) == "after"
+	for i, e := range v.list {
+		if bytes.Equal(e, args[3]) {
+			if after {
+				i++
+			}
+			v.list = append(v.list[:i], append([][]byte{args[4]}, v.list[i:]...)...)
+			return
+		}
+	}
+}
+
+func replayLMove(r *aofReplay, args [][]byte) {
+	src := r.get(args[1], "list")
+	if src == nil || len(src.list) == 0 {
+		return
+	}
+	from, to := "right", "left"
+	if len(args) > 4 {
+		from, to = strings.ToLower(string(args[3])), strings.ToLower(string(args[4]))
+	}
+	var e []byte
+	if from == "left" {
+		e, src.list = src.list[0], src.list[1:]
+	} else {
+		e, src.list = src.list[len(src.list)-1], src.list[:len(src.list)-1]
+	}
+	r.delIfEmpty(args[1], src)
+	dst := r.getOrCreate(args[2], "list")
+	if dst == nil {
+		return
+	}
+	if to == "left" {
+		dst.list = append([][]byte{e}, dst.list...)
+	} else {
+		dst.list = append(dst.list, e)
+	}
+}
+
+func replayZAdd(r *aofReplay, args [][]byte) {
+	var nx, xx, gt, lt, incr bool
+	i := 2
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToLower(string(args[i])) {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "gt":
+			gt = true
+		case "lt":
+			lt = true
+		case "incr":
+			incr = true
+		case "ch":
+		default:
+			break options
+		}
+	}
+	v := r.getOrCreate(args[1], "zset")
+	if v == nil {
+		return
+	}
+	for ; i+1 < len(args); i += 2 {
+		score, err := strconv.ParseFloat(string(args[i]), 64)
+		if err != nil {
+			continue
+		}
+		m := string(args[i+1])
+		cur, exists := v.zset[m]
+		if (nx && exists) || (xx && !exists) {
+			continue
+		}
+		if incr && exists {
+			score += cur
+		}
+		if exists && ((gt && score <= cur) || (lt && score >= cur)) {
+			continue
+		}
+		v.zset[m] = score
+	}
+	r.delIfEmpty(args[1], v)
+}
+
+func replayZIncrBy(r *aofReplay, args [][]byte) {
+	v := r.getOrCreate(args[1], "zset")
+	inc, err := strconv.ParseFloat(string(args[2]), 64)
+	if v == nil || err != nil {
+		return
+	}
+	v.zset[string(args[3])] += inc
+}
+
+func replayZRem(r *aofReplay, args [][]byte) {
+	v := r.get(args[1], "zset")
+	if v == nil {
+		return
+	}
+	for _, m := range args[2:] {
+		delete(v.zset, string(m))
+	}
+	r.delIfEmpty(args[1], v)
+}
+
+func replayZPop(r *aofReplay, args [][]byte) {
+	v := r.get(args[1], "zset")
+	if v == nil {
+		return
+	}
+	n := 1
+	if len(args) > 2 {
+		n, _ = strconv.Atoi(string(args[2]))
+	}
+	members := sortedZSet(v.zset)
+	if n > len(members) {
+		n = len(members)
+	}
+	if string(args[0]) == "zpopmax" {
+		members = members[len(members)-n:]
+	} else {
+		members = members[:n]
+	}
+	for _, m := range members {
+		delete(v.zset, m)
+	}
+	r.delIfEmpty(args[1], v)
+}
+
+func replayZRemRangeByRank(r *aofReplay, args [][]byte) {
+	v := r.get(args[1], "zset")
+	if v == nil {
+		return
+	}
+	members := sortedZSet(v.zset)
+	n := len(members)
+	start, _ := strconv.Atoi(string(args[2]))
+	stop, _ := strconv.Atoi(string(args[3]))
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	for i := start; i <= stop && i < n; i++ {
+		delete(v.zset, members[i])
+	}
+	r.delIfEmpty(args[1], v)
+}
+
+// parseScoreBound parse a score range bound such as "(1.5", "-inf" or "+inf"
+func parseScoreBound(b []byte) (float64, bool, error) {
+	s := string(b)
+	exclusive := strings.HasPrefix(s, "(")
+	if exclusive {
+		s = s[1:]
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, exclusive, err
+}
+
+func replayZRemRangeByScore(r *aofReplay, args [][]byte) {
+	v := r.get(args[1], "zset")
+	min, minEx, err1 := parseScoreBound(args[2])
+	max, maxEx, err2 := parseScoreBound(args[3])
+	if v == nil || err1 != nil || err2 != nil {
+		return
+	}
+	for m, s := range v.zset {
+		if (s > min || (!minEx && s == min)) && (s < max || (!maxEx && s == max)) {
+			delete(v.zset, m)
+		}
+	}
+	r.delIfEmpty(args[1], v)
+}
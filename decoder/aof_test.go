@@ -0,0 +1,251 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decoder
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dongmx/rdb"
+	"github.com/stretchr/testify/assert"
+)
+
+// resp encode a command in RESP
+func resp(args ...string) string {
+	s := "*" + strconv.Itoa(len(args)) + "\r\n"
+	for _, a := range args {
+		s += "$" + strconv.Itoa(len(a)) + "\r\n" + a + "\r\n"
+	}
+	return s
+}
+
+// rdbPreamble is a rdb of version 9 with strings in database 0, without checksum
+func rdbPreamble(kvs ...string) []byte {
+	b := []byte("REDIS0009\xfe\x00")
+	for i := 0; i+1 < len(kvs); i += 2 {
+		b = append(b, 0)
+		b = append(b, byte(len(kvs[i])))
+		b = append(b, kvs[i]...)
+		b = append(b, byte(len(kvs[i+1])))
+		b = append(b, kvs[i+1]...)
+	}
+	b = append(b, 0xff)
+	return append(b, make([]byte, 8)...)
+}
+
+// replayAOF decode an append only file of the files and return the keys by
+// "db:key" and the errors, the first file may start with a rdb preamble
+func replayAOF(t *testing.T, files ...string) (map[string]*Entry, []*ErrorEntry) {
+	return replayAOFTo(t, NewDecoder(), files...)
+}
+
+// replayAOFTo is replayAOF with the decoder d
+func replayAOFTo(t *testing.T, d *Decoder, files ...string) (map[string]*Entry, []*ErrorEntry) {
+	aof := NewAOF(func(read func(r *bufio.Reader) error) error {
+		for i, f := range files {
+			r := bufio.NewReader(strings.NewReader(f))
+			if i == 0 && strings.HasPrefix(f, "REDIS") {
+				if err := SkipRDB(r); err != nil {
+					return err
+				}
+			}
+			if err := read(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	// a truncated command at the end is ignored as dump does
+	if err := aof.files(aof.ReadCommands); err != io.ErrUnexpectedEOF {
+		assert.NoError(t, err)
+	}
+
+	if strings.HasPrefix(files[0], "REDIS") {
+		assert.NoError(t, rdb.Decode(strings.NewReader(files[0]), aof.Decoder(d)))
+	} else {
+		aof.Replay(d)
+	}
+	keys := map[string]*Entry{}
+	for e := range d.Entries {
+		keys[strconv.Itoa(e.DB)+":"+e.Key] = e
+	}
+	return keys, d.GetErrors()
+}
+
+func TestAOFPreamble(t *testing.T) {
+	preamble := rdbPreamble("kept", "1", "changed", "2", "deleted", "3")
+	keys, errs := replayAOF(t, string(preamble)+resp("SET", "changed", "longer value")+resp("del", "deleted")+resp("sadd", "new", "a", "b"))
+	assert.Empty(t, errs)
+	assert.Len(t, keys, 3)
+	if assert.Contains(t, keys, "0:kept") {
+		assert.Equal(t, "string", keys["0:kept"].Type)
+	}
+	if assert.Contains(t, keys, "0:changed") {
+		assert.True(t, keys["0:changed"].Bytes > keys["0:kept"].Bytes)
+	}
+	if assert.Contains(t, keys, "0:new") {
+		assert.Equal(t, "set", keys["0:new"].Type)
+		assert.Equal(t, uint64(2), keys["0:new"].NumOfElem)
+	}
+}
+
+func TestAOFKeyspaceCommands(t *testing.T) {
+	base := resp("set", "a", "1") + resp("set", "b", "2") + resp("rpush", "list", "x", "y") +
+		resp("select", "1") + resp("set", "c", "3") + resp("set", "gone", "4")
+	// each file starts at database 0
+	incr := resp("rename", "a", "renamed") + resp("move", "b", "2") + resp("expire", "list", "100") +
+		resp("select", "1") + resp("flushdb") + resp("set", "after", "5")
+	keys, errs := replayAOF(t, base, incr)
+	assert.Empty(t, errs)
+	assert.Len(t, keys, 4)
+	assert.Contains(t, keys, "0:renamed")
+	assert.Contains(t, keys, "2:b")
+	assert.Contains(t, keys, "1:after")
+	if assert.Contains(t, keys, "0:list") {
+		assert.True(t, keys["0:list"].Expiry > 0)
+		assert.Equal(t, uint64(2), keys["0:list"].NumOfElem)
+	}
+}
+
+func TestAOFUnknownCommands(t *testing.T) {
+	preamble := rdbPreamble("stale", "1", "kept", "2")
+	tail := resp("multi") + resp("xadd", "stream", "*", "f", "v") + resp("setbit", "stale", "7", "1") +
+		resp("sunionstore", "dest", "s1", "s2") + resp("sort", "list", "store", "sorted") +
+		resp("exec") + resp("frobnicate", "kept")
+	keys, errs := replayAOF(t, string(preamble)+tail)
+	// keys written by commands which can not be replayed are dropped
+	assert.Len(t, keys, 1)
+	assert.Contains(t, keys, "0:kept")
+
+	msgs := []string{}
+	for _, e := range errs {
+		msgs = append(msgs, e.Error)
+	}
+	if assert.Len(t, msgs, 5) {
+		assert.Contains(t, msgs[0], "SETBIT")
+		assert.Contains(t, msgs[1], "SORT")
+		assert.Contains(t, msgs[2], "SUNIONSTORE")
+		assert.Contains(t, msgs[3], "XADD")
+		assert.Contains(t, msgs[4], "1 FROBNICATE commands")
+		assert.Contains(t, msgs[4], "may be stale")
+	}
+}
+
+func TestAOFTruncated(t *testing.T) {
+	tail := resp("set", "a", "1") + resp("set", "b", "2")
+	tail = tail[:len(tail)-3]
+	r := bufio.NewReader(strings.NewReader(tail))
+	assert.Equal(t, io.ErrUnexpectedEOF, NewAOF(nil).ReadCommands(r))
+
+	keys, errs := replayAOF(t, tail)
+	assert.Empty(t, errs)
+	assert.Contains(t, keys, "0:a")
+	assert.NotContains(t, keys, "0:b")
+}
+
+func TestAOFEncodings(t *testing.T) {
+	ints := []string{"sadd", "ints"}
+	for i := 0; i < 500; i++ {
+		ints = append(ints, strconv.Itoa(i))
+	}
+	words := []string{"sadd", "words"}
+	for i := 0; i < 200; i++ {
+		words = append(words, "member:"+strconv.Itoa(i))
+	}
+	list := []string{"rpush", "list"}
+	for i := 0; i < 1000; i++ {
+		list = append(list, "element:"+strconv.Itoa(i))
+	}
+	long := strings.Repeat("v", 65)
+	cmds := resp("set", "str", "value") + resp(ints...) + resp("sadd", "small", "a", "b") + resp(words...) +
+		resp("hset", "hash", "f", "v") + resp("hset", "longhash", "f", long) + resp(list...) +
+		resp("zadd", "zset", "1.5", "a") + resp("zadd", "longzset", "1", long)
+
+	d := NewDecoder()
+	d.m.SetVersion("7.2.0")
+	m := &d.m
+	keys, errs := replayAOFTo(t, d, cmds)
+	assert.Empty(t, errs)
+	top := func(key string) uint64 {
+		return m.TopLevelObjOverhead([]byte(key), 0)
+	}
+	listpack := func(elems ...string) uint64 {
+		size := m.ListpackHeaderOverhead()
+		for _, e := range elems {
+			size += m.ListpackEntryOverhead([]byte(e))
+		}
+		return size
+	}
+	hashtable := func(n int, elems ...string) uint64 {
+		size := m.HashtableOverhead(uint64(n))
+		for _, e := range elems {
+			size += m.SizeofString([]byte(e))
+		}
+		return size + uint64(n)*m.HashtableEntryOverhead()
+	}
+	expected := []struct {
+		key      string
+		encoding string
+		bytes    uint64
+	}{
+		{key: "ints", encoding: "intset", bytes: top("ints") + 8 + 500*2},
+		{key: "small", encoding: "listpack", bytes: top("small") + listpack("a", "b")},
+		{key: "words", encoding: "hashtable", bytes: top("words") + hashtable(200, words[2:]...)},
+		{key: "hash", encoding: "listpack", bytes: top("hash") + listpack("f", "v")},
+		{key: "longhash", encoding: "hashtable", bytes: top("longhash") + hashtable(1, "f", long)},
+		{key: "zset", encoding: "listpack", bytes: top("zset") + listpack("a", "1.5")},
+		{key: "longzset", encoding: "skiplist"},
+	}
+	for _, exp := range expected {
+		e := keys["0:"+exp.key]
+		if !assert.NotNil(t, e, exp.key) {
+			continue
+		}
+		assert.Equal(t, exp.encoding, e.Encoding, exp.key)
+		if exp.bytes > 0 {
+			assert.Equal(t, exp.bytes, e.Bytes, exp.key)
+		}
+	}
+	if e := keys["0:str"]; assert.NotNil(t, e) {
+		assert.Equal(t, "string", e.Type)
+	}
+	// nodes of 8kb by list-max-listpack-size -2
+	if e := keys["0:list"]; assert.NotNil(t, e) {
+		size := listpack(list[2:]...) - m.ListpackHeaderOverhead()
+		nodes := (size + 8191) / 8192
+		assert.Equal(t, "quicklist", e.Encoding)
+		assert.Equal(t, top("list")+m.QuicklistOverhead(nodes)+nodes*m.ListpackHeaderOverhead()+size, e.Bytes)
+	}
+
+	// the thresholds of the redis config apply
+	conf := DefaultRedisConfig()
+	conf.SetMaxIntsetEntries = 100
+	conf.SetMaxListpackEntries = 1000
+	conf.ListMaxListpackSize = 128
+	d = NewDecoder()
+	d.m.SetVersion("7.2.0")
+	d.SetRedisConfig(conf)
+	keys, _ = replayAOFTo(t, d, cmds)
+	if e := keys["0:ints"]; assert.NotNil(t, e) {
+		assert.Equal(t, "listpack", e.Encoding)
+	}
+	if e := keys["0:list"]; assert.NotNil(t, e) {
+		size := listpack(list[2:]...) - m.ListpackHeaderOverhead()
+		assert.Equal(t, top("list")+m.QuicklistOverhead(8)+8*m.ListpackHeaderOverhead()+size, e.Bytes)
+	}
+}
@@ -17,6 +17,7 @@ package decoder
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 
 	"github.com/dongmx/rdb"
@@ -31,6 +32,61 @@ type Entry struct {
 	NumOfElem          uint64
 	LenOfLargestElem   uint64
 	FieldOfLargestElem string
+	// Idle is the LRU idle time in seconds and Freq is the LFU counter,
+	// both are -1 if the rdb file does not have them.
+	Idle int64
+	Freq int
+	// DB is the database number of the key
+	DB int
+	// Expiry is the absolute expire time in unix milliseconds, 0 if the key has no TTL
+	Expiry int64
+	// Encoding is the in-memory encoding of the value, such as ziplist,
+	// listpack, intset, hashtable, skiplist, quicklist, or int, embstr and
+	// raw of strings
+	Encoding string
+	// WhatIfBytes is the memory use with the encodings of the redis config
+	// set by SetRedisConfig, 0 if not set
+	WhatIfBytes uint64
+	// WhatIfEncoding is the encoding of the value with the redis config set
+	// by SetRedisConfig, empty if not set
+	WhatIfEncoding string
+	// NumericBytes is the memory use of a string value which is a decimal
+	// number not encoded as an integer, such as 3.25 or 0012, if its digits
+	// were stored as an integer or packed in binary, 0 for other values
+	NumericBytes uint64
+}
+
+// AuxField is an aux field of the rdb file, the metadata of the snapshot such
+// as redis-ver, redis-bits, ctime, used-mem, repl-id, repl-offset, aof-base
+// and lua scripts
+type AuxField struct {
+	Key   string
+	Value string
+}
+
+// ErrorEntry is an error of the decoding, the key is skipped
+type ErrorEntry struct {
+	// Key is the key with the error, empty if the error is not in a key
+	Key string
+	DB  int
+	// Offset in the rdbfile, -1 if unknown
+	Offset int64
+	// Skipped is the number of bytes skipped to resynchronise in tolerant mode
+	Skipped int64
+	Error   string
+}
+
+// Keyspace is the keys of a database and the memory use of its main dict and
+// expires dict, which is not in the Bytes of the entries. The dicts are sized
+// by the RESIZEDB hint of the rdb file, or by the keys if they are more.
+type Keyspace struct {
+	DB               int
+	Keys             uint64
+	Expires          uint64
+	DBSize           uint64
+	ExpiresSize      uint64
+	MainDictBytes    uint64
+	ExpiresDictBytes uint64
 }
 
 // Decoder decode rdb file
@@ -41,10 +97,29 @@ type Decoder struct {
 	usedMem int64
 	ctime   int64
 	count   int
-	rdbVer int
+	rdbVer  int
+	db      int
+
+	// only keys of these databases are sent if not nil
+	dbFilter map[int]bool
+
+	aux []*AuxField
+	// keyspaces of the databases by number
+	keyspaces map[int]*Keyspace
+	// entries are estimated as if whatIf applied if not nil
+	whatIf *RedisConfig
+	elems  whatIfElems
+
+	// quicklist nodes but listCompressDepth nodes at each end are
+	// compressed, lzf samples the compression ratio of the nodes
+	listCompressDepth int64
+	lzf               lzfSample
 
 	currentInfo  *rdb.Info
 	currentEntry *Entry
+	// entryErr is the error of the current entry, which is not sent if set
+	entryErr *ErrorEntry
+	errors   []*ErrorEntry
 
 	nopdecoder.NopDecoder
 }
@@ -53,13 +128,99 @@ type Decoder struct {
 func NewDecoder() *Decoder {
 	return &Decoder{
 		Entries: make(chan *Entry, 1024),
-		m:       MemProfiler{},
+		m:       NewMemProfiler(),
+	}
+}
+
+// FilterDB only sends keys of the given databases
+func (d *Decoder) FilterDB(dbs ...int) {
+	d.dbFilter = map[int]bool{}
+	for _, db := range dbs {
+		d.dbFilter[db] = true
 	}
 }
 
 func (d *Decoder) sendEntry() {
-	d.Entries <- d.currentEntry
+	if d.entryErr == nil && (d.dbFilter == nil || d.dbFilter[d.currentEntry.DB]) {
+		d.countKeyspace(d.currentEntry)
+		if d.whatIf != nil {
+			d.currentEntry.WhatIfBytes, d.currentEntry.WhatIfEncoding = d.whatIfBytes(d.currentEntry)
+		}
+		d.Entries <- d.currentEntry
+	}
 	d.currentEntry = nil
+	d.entryErr = nil
+	d.elems = whatIfElems{}
+}
+
+// keyspace return the keyspace of database db
+func (d *Decoder) keyspace(db int) *Keyspace {
+	if d.keyspaces == nil {
+		d.keyspaces = map[int]*Keyspace{}
+	}
+	ks, ok := d.keyspaces[db]
+	if !ok {
+		ks = &Keyspace{DB: db}
+		d.keyspaces[db] = ks
+	}
+	return ks
+}
+
+// countKeyspace count e in the keyspace of its database
+func (d *Decoder) countKeyspace(e *Entry) {
+	ks := d.keyspace(e.DB)
+	ks.Keys++
+	if e.Expiry > 0 {
+		ks.Expires++
+	}
+}
+
+// GetKeyspaces return the keyspaces of the databases sorted by number, it
+// must be called after Entries is closed
+func (d *Decoder) GetKeyspaces() []*Keyspace {
+	keyspaces := []*Keyspace{}
+	for _, ks := range d.keyspaces {
+		ks.MainDictBytes = d.m.KeyspaceOverhead(maxUint64(ks.Keys, ks.DBSize))
+		ks.ExpiresDictBytes = d.m.KeyspaceOverhead(maxUint64(ks.Expires, ks.ExpiresSize))
+		keyspaces = append(keyspaces, ks)
+	}
+	sort.Slice(keyspaces, func(i, j int) bool { return keyspaces[i].DB < keyspaces[j].DB })
+	return keyspaces
+}
+
+// skipEntry records an error of the current entry, the entry is not sent
+func (d *Decoder) skipEntry(format string, a ...interface{}) {
+	if d.entryErr != nil {
+		return
+	}
+	d.entryErr = &ErrorEntry{
+		Key:    d.currentEntry.Key,
+		DB:     d.db,
+		Offset: d.currentInfo.Offset,
+		Error:  fmt.Sprintf(format, a...),
+	}
+	d.errors = append(d.errors, d.entryErr)
+}
+
+// AddError records an error of the decoding, the key being decoded if any is
+// dropped. Errors of the rdb parser are *rdb.DecodeError.
+func (d *Decoder) AddError(err error) {
+	e := &ErrorEntry{DB: d.db, Offset: -1, Error: err.Error()}
+	if derr, ok := err.(*rdb.DecodeError); ok {
+		e.Key = derr.Key
+		e.Offset = derr.Offset
+		e.Skipped = derr.Skipped
+		e.Error = derr.Err.Error()
+	}
+	d.errors = append(d.errors, e)
+	d.currentEntry = nil
+	d.entryErr = nil
+}
+
+// GetErrors return the errors of the decoding, it must be called after
+// Entries is closed
+func (d *Decoder) GetErrors() []*ErrorEntry {
+	return d.errors
 }
 
 func (d *Decoder) GetTimestamp() int64 {
@@ -74,7 +235,33 @@ func (d *Decoder) StartRDB(ver int) {
 	d.rdbVer = ver
 }
 
+// StartDatabase is called when database n starts.
+func (d *Decoder) StartDatabase(n int) {
+	d.db = n
+}
+
+// ResizeDatabase is called with the number of keys and keys with an expiry of
+// the current database, redis sizes the dicts of the database by them
+func (d *Decoder) ResizeDatabase(dbSize, expiresSize uint32) {
+	if d.dbFilter != nil && !d.dbFilter[d.db] {
+		return
+	}
+	ks := d.keyspace(d.db)
+	ks.DBSize = uint64(dbSize)
+	ks.ExpiresSize = uint64(expiresSize)
+}
+
+func maxUint64(a, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Aux keeps all aux fields, and configures the memory profiler from the word
+// size and the version of redis
 func (d *Decoder) Aux(key, value []byte) {
+	d.aux = append(d.aux, &AuxField{Key: string(key), Value: string(value)})
 	switch string(key) {
 	case "ctime":
 		{
@@ -94,9 +281,45 @@ func (d *Decoder) Aux(key, value []byte) {
 			d.usedMem = n
 		}
 
+	case "redis-bits":
+		n, err := strconv.Atoi(string(value))
+		if err == nil {
+			err = d.m.SetBits(n)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "redis-bits:", err)
+		}
+
+	case "redis-ver":
+		if err := d.m.SetVersion(string(value)); err != nil {
+			fmt.Fprintln(os.Stderr, "redis-ver:", err)
+		}
 	}
 }
 
+// SetMemoryModel set the memory model the memory use is estimated with, it
+// takes precedence over the redis-ver and redis-bits aux fields
+func (d *Decoder) SetMemoryModel(mm *MemoryModel) error {
+	return d.m.SetMemoryModel(mm)
+}
+
+// SetMaxmemoryPolicy set the maxmemory-policy of redis the memory use is
+// estimated for
+func (d *Decoder) SetMaxmemoryPolicy(policy string) error {
+	return d.m.SetMaxmemoryPolicy(policy)
+}
+
+// GetMemoryModel return the name and the sizes of the memory model in use,
+// which depends on the aux fields read so far
+func (d *Decoder) GetMemoryModel() (string, MemoryModel) {
+	return d.m.GetMemoryModel()
+}
+
+// GetAux return the aux fields of the rdb file in their order
+func (d *Decoder) GetAux() []*AuxField {
+	return d.aux
+}
+
 func (d *Decoder) StartStream(key []byte, cardinality, expiry int64, info *rdb.Info) {
 	keyStr := string(key)
 	bytes := d.m.TopLevelObjOverhead(key, expiry)
@@ -108,8 +331,13 @@ func (d *Decoder) StartStream(key []byte, cardinality, expiry int64, info *rdb.I
 		Key:              keyStr,
 		Bytes:            bytes,
 		Type:             "stream",
+		Encoding:         info.Encoding,
 		NumOfElem:        0,
 		LenOfLargestElem: 0,
+		Idle:             info.Idle,
+		Freq:             info.Freq,
+		DB:               d.db,
+		Expiry:           expiry,
 	}
 }
 
@@ -139,16 +367,25 @@ func (d *Decoder) EndStream(key []byte, items uint64, lastEntryID string, cgroup
 // Set is called once for each string key.
 func (d *Decoder) Set(key, value []byte, expiry int64, info *rdb.Info) {
 	keyStr := string(key)
-	bytes := d.m.TopLevelObjOverhead(key, expiry)
-	bytes += d.m.SizeofString(value)
+	obj := d.m.StringObjOverhead(value)
+	bytes := d.m.TopLevelKeyOverhead(key, expiry) + obj
 
 	e := &Entry{
 		Key:       keyStr,
 		Bytes:     bytes,
 		Type:      "string",
+		Encoding:  d.m.StringEncoding(value),
 		NumOfElem: d.m.ElemLen(value),
+		Idle:      info.Idle,
+		Freq:      info.Freq,
+		DB:        d.db,
+		Expiry:    expiry,
+	}
+	if packed, ok := d.m.NumericStringOverhead(value); ok && packed < obj {
+		e.NumericBytes = bytes - obj + packed
 	}
-	d.Entries <- e
+	d.currentEntry = e
+	d.sendEntry()
 }
 
 // StartHash is called at the beginning of a hash.
@@ -162,8 +399,6 @@ func (d *Decoder) StartHash(key []byte, length, expiry int64, info *rdb.Info) {
 		bytes += uint64(info.SizeOfValue)
 	} else if info.Encoding == "hashtable" {
 		bytes += d.m.HashtableOverhead(uint64(length))
-	} else {
-		panic(fmt.Sprintf("unexpected size(0) or encoding:%s", info.Encoding))
 	}
 
 	d.currentInfo = info
@@ -171,8 +406,17 @@ func (d *Decoder) StartHash(key []byte, length, expiry int64, info *rdb.Info) {
 		Key:       keyStr,
 		Bytes:     bytes,
 		Type:      "hash",
+		Encoding:  info.Encoding,
 		NumOfElem: uint64(length),
+		Idle:      info.Idle,
+		Freq:      info.Freq,
+		DB:        d.db,
+		Expiry:    expiry,
+	}
+	if info.SizeOfValue <= 0 && info.Encoding != "hashtable" {
+		d.skipEntry("unexpected size(0) or encoding:%s", info.Encoding)
 	}
+	d.whatIfStart("hash")
 }
 
 // Hset is called once for each field=value pair in a hash.
@@ -184,13 +428,14 @@ func (d *Decoder) Hset(key, field, value []byte) {
 		e.FieldOfLargestElem = string(field)
 		e.LenOfLargestElem = lenOfElem
 	}
+	d.whatIfHset(field, value)
 
 	if d.currentInfo.Encoding == "hashtable" {
 		e.Bytes += d.m.SizeofString(field)
 		e.Bytes += d.m.SizeofString(value)
 		e.Bytes += d.m.HashtableEntryOverhead()
 
-		if d.rdbVer < 8 {
+		if d.m.elemRobj(d.rdbVer) {
 			e.Bytes += 2 * d.m.RobjOverhead()
 		}
 	}
@@ -205,6 +450,8 @@ func (d *Decoder) EndHash(key []byte) {
 // Sadd will be called exactly cardinality times before EndSet.
 func (d *Decoder) StartSet(key []byte, cardinality, expiry int64, info *rdb.Info) {
 	d.StartHash(key, cardinality, expiry, info)
+	d.currentEntry.Type = "set"
+	d.whatIfStart("set")
 }
 
 // Sadd is called once for each member of a set.
@@ -215,12 +462,13 @@ func (d *Decoder) Sadd(key, member []byte) {
 		e.FieldOfLargestElem = string(member)
 		e.LenOfLargestElem = lenOfElem
 	}
+	d.whatIfSadd(member)
 
 	if d.currentInfo.Encoding == "hashtable" {
 		e.Bytes += d.m.SizeofString(member)
 		e.Bytes += d.m.HashtableEntryOverhead()
 
-		if d.rdbVer < 8 {
+		if d.m.elemRobj(d.rdbVer) {
 			e.Bytes += d.m.RobjOverhead()
 		}
 	}
@@ -247,8 +495,14 @@ func (d *Decoder) StartList(key []byte, length, expiry int64, info *rdb.Info) {
 		Key:       keyStr,
 		Bytes:     bytes,
 		Type:      "list",
+		Encoding:  info.Encoding,
 		NumOfElem: 0,
+		Idle:      info.Idle,
+		Freq:      info.Freq,
+		DB:        d.db,
+		Expiry:    expiry,
 	}
+	d.whatIfStart("list")
 }
 
 // Rpush is called once for each value in a list.
@@ -259,7 +513,11 @@ func (d *Decoder) Rpush(key, value []byte) {
 
 	switch d.currentInfo.Encoding {
 	case "quicklist":
-		e.Bytes += d.m.ZiplistEntryOverhead(value)
+		if d.currentInfo.NodeEncoding == "listpack" {
+			e.Bytes += d.m.ListpackEntryOverhead(value)
+		} else {
+			e.Bytes += d.m.ZiplistEntryOverhead(value)
+		}
 
 	case "ziplist":
 		e.Bytes += d.m.ZiplistEntryOverhead(value)
@@ -273,12 +531,12 @@ func (d *Decoder) Rpush(key, value []byte) {
 		e.Bytes += d.m.LinkedListEntryOverhead()
 		e.Bytes += sizeInlist
 
-		if d.rdbVer < 8 {
+		if d.m.elemRobj(d.rdbVer) {
 			e.Bytes += d.m.RobjOverhead()
 		}
 
 	default:
-		panic(fmt.Sprintf("unknown encoding:%s", d.currentInfo.Encoding))
+		d.skipEntry("unknown encoding:%s", d.currentInfo.Encoding)
 	}
 
 	lenOfElem := d.m.ElemLen(value)
@@ -286,6 +544,7 @@ func (d *Decoder) Rpush(key, value []byte) {
 		e.FieldOfLargestElem = string(value)
 		e.LenOfLargestElem = lenOfElem
 	}
+	d.whatIfRpush(value)
 }
 
 // EndList is called when there are no more values in a list.
@@ -295,7 +554,12 @@ func (d *Decoder) EndList(key []byte) {
 	switch d.currentInfo.Encoding {
 	case "quicklist":
 		e.Bytes += d.m.QuicklistOverhead(d.currentInfo.Zips)
-		e.Bytes += d.m.ZiplistHeaderOverhead() * d.currentInfo.Zips
+		if d.currentInfo.NodeEncoding == "listpack" {
+			e.Bytes += d.m.ListpackHeaderOverhead() * d.currentInfo.Zips
+		} else {
+			e.Bytes += d.m.ZiplistHeaderOverhead() * d.currentInfo.Zips
+		}
+		e.Bytes = d.compressNodes(e.Bytes, d.currentInfo)
 
 	case "ziplist":
 		e.Bytes += d.m.ZiplistHeaderOverhead()
@@ -304,12 +568,88 @@ func (d *Decoder) EndList(key []byte) {
 		e.Bytes += d.m.LinkedlistOverhead()
 
 	default:
-		panic(fmt.Sprintf("unknown encoding:%s", d.currentInfo.Encoding))
+		d.skipEntry("unknown encoding:%s", d.currentInfo.Encoding)
 	}
 
 	d.sendEntry()
 }
 
+// SetListCompressDepth set the list-compress-depth of redis, the quicklist
+// nodes saved uncompressed but depth nodes at each end are estimated LZF
+// compressed
+func (d *Decoder) SetListCompressDepth(depth int64) {
+	d.listCompressDepth = depth
+}
+
+// compressNodes get memory use bytes of the quicklist of info with its
+// compressed nodes. A node saved compressed in the rdb file is loaded as it
+// is, and counted at the size it is saved with. A node saved uncompressed is
+// compressed by the sampled ratio if it is an interior node of the depth.
+func (d *Decoder) compressNodes(bytes uint64, info *rdb.Info) uint64 {
+	for _, node := range info.Nodes {
+		d.lzf.add(node)
+	}
+	depth := d.listCompressDepth
+	interior := depth > 0 && uint64(2*depth) < info.Zips
+	for i, node := range info.Nodes {
+		size := uint64(node.Size)
+		if node.CompressedSize == 0 && (!interior || int64(i) < depth || uint64(i) >= info.Zips-uint64(depth)) {
+			continue
+		}
+		compressed, ok := d.lzf.compress(node)
+		if node.CompressedSize > 0 {
+			compressed, ok = uint64(node.CompressedSize), true
+		}
+		if !ok || size > bytes {
+			continue
+		}
+		bytes = bytes - size + d.m.QuicklistLZFOverhead(compressed)
+	}
+	return bytes
+}
+
+// lzfSample is the quicklist nodes saved LZF compressed in the rdb file
+type lzfSample struct {
+	size       uint64
+	compressed uint64
+}
+
+// redis does not compress quicklist nodes smaller than minCompressBytes, or
+// which are not smaller by minCompressImprove bytes at least
+// See https://github.com/antirez/redis/blob/unstable/src/quicklist.c
+const (
+	minCompressBytes   = 48
+	minCompressImprove = 8
+)
+
+func (s *lzfSample) add(node rdb.QuicklistNode) {
+	if node.CompressedSize > 0 {
+		s.size += uint64(node.Size)
+		s.compressed += uint64(node.CompressedSize)
+	}
+}
+
+// ratio return the compression ratio of the nodes sampled, 1 if none
+func (s *lzfSample) ratio() float64 {
+	if s.size == 0 {
+		return 1
+	}
+	return float64(s.compressed) / float64(s.size)
+}
+
+// compress get the compressed size of node, ok is false if redis would keep
+// it uncompressed
+func (s *lzfSample) compress(node rdb.QuicklistNode) (size uint64, ok bool) {
+	if node.Size < minCompressBytes {
+		return 0, false
+	}
+	size = uint64(node.CompressedSize)
+	if size == 0 {
+		size = uint64(float64(node.Size) * s.ratio())
+	}
+	return size, size+minCompressImprove < uint64(node.Size)
+}
+
 // StartZSet is called at the beginning of a sorted set.
 // Zadd will be called exactly cardinality times before EndZSet.
 func (d *Decoder) StartZSet(key []byte, cardinality, expiry int64, info *rdb.Info) {
@@ -322,16 +662,23 @@ func (d *Decoder) StartZSet(key []byte, cardinality, expiry int64, info *rdb.Inf
 		bytes += uint64(info.SizeOfValue)
 	} else if info.Encoding == "skiplist" {
 		bytes += d.m.SkiplistOverhead(uint64(cardinality))
-	} else {
-		panic(fmt.Sprintf("unexpected size(0) or encoding:%s", info.Encoding))
 	}
 
 	d.currentEntry = &Entry{
 		Key:       keyStr,
 		Bytes:     bytes,
 		Type:      "sortedset",
+		Encoding:  info.Encoding,
 		NumOfElem: uint64(cardinality),
+		Idle:      info.Idle,
+		Freq:      info.Freq,
+		DB:        d.db,
+		Expiry:    expiry,
+	}
+	if info.SizeOfValue <= 0 && info.Encoding != "skiplist" {
+		d.skipEntry("unexpected size(0) or encoding:%s", info.Encoding)
 	}
+	d.whatIfStart("sortedset")
 }
 
 // Zadd is called once for each member of a sorted set.
@@ -342,13 +689,14 @@ func (d *Decoder) Zadd(key []byte, score float64, member []byte) {
 		e.FieldOfLargestElem = string(member)
 		e.LenOfLargestElem = lenOfElem
 	}
+	d.whatIfZadd(key, score, member)
 
 	if d.currentInfo.Encoding == "skiplist" {
 		e.Bytes += 8 // sizeof(score)
 		e.Bytes += d.m.SizeofString(member)
-		e.Bytes += d.m.SkiplistEntryOverhead()
+		e.Bytes += d.m.SkiplistEntryOverhead(key, member)
 
-		if d.rdbVer < 8 {
+		if d.m.elemRobj(d.rdbVer) {
 			e.Bytes += d.m.RobjOverhead()
 		}
 	}
@@ -359,6 +707,28 @@ func (d *Decoder) EndZSet(key []byte) {
 	d.sendEntry()
 }
 
+// Module is called once for each module type key, e.g. ReJSON-RL or MBbloom--.
+// The value can only be understood by the module itself, so its size in
+// the rdb file is used as an estimate of its memory use.
+func (d *Decoder) Module(key []byte, moduleName string, expiry int64, info *rdb.Info) {
+	keyStr := string(key)
+	bytes := d.m.TopLevelObjOverhead(key, expiry)
+	bytes += d.m.mallocOverhead(uint64(info.SizeOfValue))
+
+	e := &Entry{
+		Key:      keyStr,
+		Bytes:    bytes,
+		Type:     moduleName,
+		Encoding: info.Encoding,
+		Idle:     info.Idle,
+		Freq:     info.Freq,
+		DB:       d.db,
+		Expiry:   expiry,
+	}
+	d.currentEntry = e
+	d.sendEntry()
+}
+
 // EndRDB is called when parsing of the RDB file is complete.
 func (d *Decoder) EndRDB() {
 	close(d.Entries)
@@ -0,0 +1,120 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dongmx/rdb"
+	"github.com/stretchr/testify/assert"
+)
+
+// rdbFile is a rdb of version 9 with the records in database 0, without
+// checksum
+func rdbFile(records ...[]byte) []byte {
+	b := []byte("REDIS0009\xfe\x00")
+	for _, r := range records {
+		b = append(b, r...)
+	}
+	b = append(b, 0xff)
+	return append(b, make([]byte, 8)...)
+}
+
+// rdbKey is a key record of type typ with the serialized value
+func rdbKey(typ byte, key string, value []byte) []byte {
+	b := []byte{typ, byte(len(key))}
+	b = append(b, key...)
+	return append(b, value...)
+}
+
+// moduleID is the 64 bit length of the module id of a 9 characters module
+// type name and encoding version
+func moduleID(name string, ver uint64) []byte {
+	const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	id := uint64(0)
+	for i := 0; i < len(name); i++ {
+		id = id<<6 | uint64(strings.IndexByte(charset, name[i]))
+	}
+	b := []byte{0x81, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(b[1:], id<<10|ver)
+	return b
+}
+
+// decodeRDB decode b and return the keys by name and the errors
+func decodeRDB(b []byte, tolerant bool) (map[string]*Entry, []*ErrorEntry, error) {
+	d := NewDecoder()
+	opts := rdb.Options{}
+	if tolerant {
+		opts.OnError = func(err *rdb.DecodeError) {
+			d.AddError(err)
+		}
+	}
+	_, _, err := rdb.DecodeWithOptions(bytes.NewReader(b), d, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys := map[string]*Entry{}
+	for e := range d.Entries {
+		keys[e.Key] = e
+	}
+	return keys, d.GetErrors(), nil
+}
+
+func TestModule(t *testing.T) {
+	// a module value of every opcode: uint, string, float, double and EOF
+	value := moduleID("MBbloom--", 3)
+	value = append(value, 2, 50, 5, 3, 'a', 'b', 'c', 3, 0, 0, 0, 0, 4)
+	value = append(value, make([]byte, 8)...)
+	value = append(value, 0)
+	// module aux data: module id, when opcode, when and a value
+	aux := append([]byte{247}, moduleID("ReJSON-RL", 1)...)
+	aux = append(aux, 2, 2, 5, 1, 'x', 0)
+
+	keys, errs, err := decodeRDB(rdbFile(aux, rdbKey(7, "bloom", value), rdbKey(0, "after", []byte{1, '1'})), false)
+	assert.NoError(t, err)
+	assert.Empty(t, errs)
+	assert.Len(t, keys, 2)
+	assert.Contains(t, keys, "after")
+	if assert.Contains(t, keys, "bloom") {
+		e := keys["bloom"]
+		assert.Equal(t, "MBbloom--", e.Type)
+		assert.Equal(t, "MBbloom--", e.Encoding)
+		m := NewMemProfiler()
+		assert.Equal(t, m.TopLevelObjOverhead([]byte("bloom"), 0)+m.mallocOverhead(uint64(len(value))), e.Bytes)
+	}
+}
+
+func TestModuleV1(t *testing.T) {
+	// a value of RDB_TYPE_MODULE has no opcodes to find its end
+	value := append(moduleID("graphdata", 0), 0xc8, 0xc8, 0xc8, 0xc8)
+	b := rdbFile(rdbKey(0, "before", []byte{1, '1'}), rdbKey(6, "graph", value), rdbKey(0, "after", []byte{1, '2'}))
+
+	_, _, err := decodeRDB(b, false)
+	assert.True(t, errors.Is(err, rdb.ErrModuleV1), "%v", err)
+
+	keys, errs, err := decodeRDB(b, true)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Contains(t, keys, "before")
+	assert.Contains(t, keys, "after")
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "graph", errs[0].Key)
+		assert.Contains(t, errs[0].Error, "graphdata")
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// allocators of redis
+const (
+	AllocatorJemalloc = "jemalloc"
+	AllocatorLibc     = "libc"
+)
+
+// MemoryModel is the sizes in bytes of the structs of redis and the size
+// classes of its allocator, which the memory use is estimated with.
+//
+// The built-in models are profiles per major version of redis and allocator,
+// named like redis7-jemalloc. In a model file the sizes which are zero keep
+// the size of the profile the file is based on.
+type MemoryModel struct {
+	// Profile is the built-in model a model file is based on, the profile
+	// is picked by redis-ver and redis-bits if it is empty. It can also be
+	// an allocator only, e.g. libc.
+	Profile string `json:"profile,omitempty" yaml:"profile,omitempty"`
+
+	PointerSize uint64 `json:"pointer_size,omitempty" yaml:"pointer_size,omitempty"`
+	LongSize    uint64 `json:"long_size,omitempty" yaml:"long_size,omitempty"`
+	// Robj is type, encoding and lru bit fields + refcount + ptr
+	Robj uint64 `json:"robj,omitempty" yaml:"robj,omitempty"`
+	// SdsHeaders is the header of sdshdr5, 8, 16, 32 and 64, or the only
+	// header of sds before redis 3.2
+	SdsHeaders []uint64 `json:"sds_headers,omitempty" yaml:"sds_headers,omitempty"`
+	// EmbstrLimit is the longest string embedded in its robj, -1 if none
+	EmbstrLimit int64 `json:"embstr_limit,omitempty" yaml:"embstr_limit,omitempty"`
+	// SharedIntegers is the number of shared integer objects, -1 if none
+	SharedIntegers int64  `json:"shared_integers,omitempty" yaml:"shared_integers,omitempty"`
+	DictEntry      uint64 `json:"dict_entry,omitempty" yaml:"dict_entry,omitempty"`
+	// Dict is a dict without its tables
+	Dict          uint64 `json:"dict,omitempty" yaml:"dict,omitempty"`
+	List          uint64 `json:"list,omitempty" yaml:"list,omitempty"`
+	ListNode      uint64 `json:"list_node,omitempty" yaml:"list_node,omitempty"`
+	Quicklist     uint64 `json:"quicklist,omitempty" yaml:"quicklist,omitempty"`
+	QuicklistNode uint64 `json:"quicklist_node,omitempty" yaml:"quicklist_node,omitempty"`
+	// Zset is a zset and its zskiplist without the dict
+	Zset uint64 `json:"zset,omitempty" yaml:"zset,omitempty"`
+	// SkiplistNode is a zskiplistNode without levels, SkiplistLevel is a level
+	SkiplistNode  uint64 `json:"skiplist_node,omitempty" yaml:"skiplist_node,omitempty"`
+	SkiplistLevel uint64 `json:"skiplist_level,omitempty" yaml:"skiplist_level,omitempty"`
+
+	// Allocator is jemalloc or libc
+	Allocator string `json:"allocator,omitempty" yaml:"allocator,omitempty"`
+	// SizeClasses of jemalloc
+	SizeClasses []uint64 `json:"size_classes,omitempty" yaml:"size_classes,omitempty"`
+	// a libc malloc chunk is the size + MallocHeader aligned to MallocAlign,
+	// and at least MallocMin
+	MallocHeader uint64 `json:"malloc_header,omitempty" yaml:"malloc_header,omitempty"`
+	MallocAlign  uint64 `json:"malloc_align,omitempty" yaml:"malloc_align,omitempty"`
+	MallocMin    uint64 `json:"malloc_min,omitempty" yaml:"malloc_min,omitempty"`
+}
+
+// ParseProfile parses the name of a built-in model, redis<major>-<allocator>,
+// redis<major> or <allocator>. major is 0 if the name does not have it.
+func ParseProfile(name string) (major int, allocator string, err error) {
+	allocator = AllocatorJemalloc
+	version := name
+	if i := strings.LastIndexByte(name, '-'); i >= 0 {
+		version, allocator = name[:i], name[i+1:]
+	} else if name == AllocatorJemalloc || name == AllocatorLibc {
+		version, allocator = "", name
+	}
+	if allocator != AllocatorJemalloc && allocator != AllocatorLibc {
+		return 0, "", fmt.Errorf("unknown allocator %q of memory model %q", allocator, name)
+	}
+	if version != "" {
+		major, err = strconv.Atoi(strings.TrimPrefix(version, "redis"))
+		if err != nil || !strings.HasPrefix(version, "redis") || major < 2 || major > 7 {
+			return 0, "", fmt.Errorf("unknown memory model %q", name)
+		}
+	}
+	return major, allocator, nil
+}
+
+// LoadMemoryModel return the built-in model of a profile name, or read a
+// model file in JSON or YAML
+func LoadMemoryModel(nameOrPath string) (*MemoryModel, error) {
+	if _, err := os.Stat(nameOrPath); os.IsNotExist(err) {
+		if _, _, perr := ParseProfile(nameOrPath); perr != nil {
+			return nil, fmt.Errorf("%v, and no such file", perr)
+		}
+		return &MemoryModel{Profile: nameOrPath}, nil
+	}
+
+	content, err := ioutil.ReadFile(nameOrPath)
+	if err != nil {
+		return nil, err
+	}
+	mm := &MemoryModel{}
+	switch strings.ToLower(filepath.Ext(nameOrPath)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(content, mm)
+	default:
+		dec := json.NewDecoder(strings.NewReader(string(content)))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(mm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid memory model %s: %v", nameOrPath, err)
+	}
+	if mm.Profile != "" {
+		if _, _, err := ParseProfile(mm.Profile); err != nil {
+			return nil, err
+		}
+	}
+	if mm.Allocator != "" && mm.Allocator != AllocatorJemalloc && mm.Allocator != AllocatorLibc {
+		return nil, fmt.Errorf("unknown allocator %q in memory model %s", mm.Allocator, nameOrPath)
+	}
+	return mm, nil
+}
+
+// BuiltinMemoryModel return the built-in model of a major version of redis,
+// 0 if unknown, with a word size of bits and an allocator
+func BuiltinMemoryModel(major, bits int, allocator string) *MemoryModel {
+	ptr := uint64(bits / 8)
+	long := ptr
+	mm := &MemoryModel{
+		PointerSize: ptr,
+		LongSize:    long,
+		Robj:        4 + 4 + ptr,
+		// flags, or len, alloc and flags of uint8 to uint64
+		SdsHeaders:     []uint64{1, 3, 5, 9, 17},
+		EmbstrLimit:    44,
+		SharedIntegers: 10000,
+		DictEntry:      3 * ptr,
+		// type and privdata + 2 dictht of table, size, sizemask and used +
+		// rehashidx + iterators
+		Dict: 4 + 7*long + 4*ptr,
+		// head, tail, dup, free and match + len
+		List:     long + 5*ptr,
+		ListNode: 3 * ptr,
+		// head and tail + count + len + fill and compress bit fields, len is
+		// an unsigned long since redis 4.0
+		Quicklist: 2*ptr + long + 4 + 4,
+		// prev, next and zl + unsigned int sz + 32 bits of bit fields
+		QuicklistNode: 3*ptr + 4 + 4,
+		// dict and zsl + header and tail + length + level
+		Zset: 2*ptr + 2*ptr + 16,
+		// ele and backward + score, and forward + span of each level
+		SkiplistNode:  2*ptr + 8,
+		SkiplistLevel: ptr + 8,
+		Allocator:     allocator,
+	}
+	if major > 0 && major < 3 {
+		// sdshdr has int len and int free, EMBSTR is since redis 3.0
+		mm.SdsHeaders = []uint64{4 + 4}
+		mm.EmbstrLimit = -1
+	}
+	if major >= 4 {
+		mm.Quicklist = 2*ptr + 2*long + 8
+	}
+	if major >= 7 {
+		// dictht is merged into dict: type + 2 tables + 2 used + rehashidx +
+		// pauserehash and 2 size exponents
+		mm.Dict = alignTo(3*ptr+3*long+4, ptr)
+		// sz is a size_t
+		mm.QuicklistNode = 3*ptr + alignTo(long+4, ptr)
+	}
+	switch allocator {
+	case AllocatorLibc:
+		// glibc malloc chunks have a size_t header and are aligned to 2 size_t
+		mm.MallocHeader = ptr
+		mm.MallocAlign = 2 * ptr
+		mm.MallocMin = 4 * ptr
+	default:
+		mm.SizeClasses = jemallocSizeClasses
+	}
+	return mm
+}
+
+// merge the non-zero sizes of o into mm
+func (mm *MemoryModel) merge(o *MemoryModel) {
+	sizes := []struct{ dst, src *uint64 }{
+		{&mm.PointerSize, &o.PointerSize},
+		{&mm.LongSize, &o.LongSize},
+		{&mm.Robj, &o.Robj},
+		{&mm.DictEntry, &o.DictEntry},
+		{&mm.Dict, &o.Dict},
+		{&mm.List, &o.List},
+		{&mm.ListNode, &o.ListNode},
+		{&mm.Quicklist, &o.Quicklist},
+		{&mm.QuicklistNode, &o.QuicklistNode},
+		{&mm.Zset, &o.Zset},
+		{&mm.SkiplistNode, &o.SkiplistNode},
+		{&mm.SkiplistLevel, &o.SkiplistLevel},
+		{&mm.MallocHeader, &o.MallocHeader},
+		{&mm.MallocAlign, &o.MallocAlign},
+		{&mm.MallocMin, &o.MallocMin},
+	}
+	for _, s := range sizes {
+		if *s.src != 0 {
+			*s.dst = *s.src
+		}
+	}
+	if o.SharedIntegers != 0 {
+		mm.SharedIntegers = o.SharedIntegers
+	}
+	if o.EmbstrLimit != 0 {
+		mm.EmbstrLimit = o.EmbstrLimit
+	}
+	if len(o.SdsHeaders) > 0 {
+		mm.SdsHeaders = o.SdsHeaders
+	}
+	if o.Allocator != "" {
+		mm.Allocator = o.Allocator
+	}
+	if len(o.SizeClasses) > 0 {
+		mm.SizeClasses = o.SizeClasses
+	}
+}
+
+// isProfile reports whether mm is a built-in profile without sizes
+func (mm *MemoryModel) isProfile() bool {
+	sizes := *mm
+	sizes.Profile = ""
+	return reflect.DeepEqual(sizes, MemoryModel{})
+}
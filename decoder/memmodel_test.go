@@ -0,0 +1,110 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decoder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProfile(t *testing.T) {
+	for name, want := range map[string]struct {
+		major     int
+		allocator string
+	}{
+		"redis7-jemalloc": {7, AllocatorJemalloc},
+		"redis2-libc":     {2, AllocatorLibc},
+		"redis5":          {5, AllocatorJemalloc},
+		"libc":            {0, AllocatorLibc},
+	} {
+		major, allocator, err := ParseProfile(name)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, want.major, major, name)
+			assert.Equal(t, want.allocator, allocator, name)
+		}
+	}
+	for _, name := range []string{"redis8", "redis1-libc", "redis7-tcmalloc", "valkey7", "7-jemalloc"} {
+		_, _, err := ParseProfile(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestLoadMemoryModel(t *testing.T) {
+	mm, err := LoadMemoryModel("redis6-libc")
+	if assert.NoError(t, err) {
+		assert.True(t, mm.isProfile())
+		assert.Equal(t, "redis6-libc", mm.Profile)
+	}
+	_, err = LoadMemoryModel("no-such-model")
+	assert.Error(t, err)
+
+	dir, err := ioutil.TempDir("", "memmodel")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"model.yaml":      "profile: redis5-libc\nrobj: 24\nsds_headers: [2, 4]\n",
+		"model.json":      `{"profile": "redis7", "dict_entry": 32, "embstr_limit": -1}`,
+		"unknown.json":    `{"robj": 24, "no_such_size": 1}`,
+		"allocator.yml":   "allocator: tcmalloc\n",
+		"badprofile.json": `{"profile": "redis9"}`,
+	}
+	for name, content := range files {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)) {
+			return
+		}
+	}
+
+	mm, err = LoadMemoryModel(filepath.Join(dir, "model.yaml"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "redis5-libc", mm.Profile)
+		assert.Equal(t, uint64(24), mm.Robj)
+		assert.Equal(t, []uint64{2, 4}, mm.SdsHeaders)
+		assert.False(t, mm.isProfile())
+	}
+	mm, err = LoadMemoryModel(filepath.Join(dir, "model.json"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "redis7", mm.Profile)
+		assert.Equal(t, uint64(32), mm.DictEntry)
+		assert.Equal(t, int64(-1), mm.EmbstrLimit)
+	}
+	for _, name := range []string{"unknown.json", "allocator.yml", "badprofile.json"} {
+		_, err = LoadMemoryModel(filepath.Join(dir, name))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestMergeMemoryModel(t *testing.T) {
+	mm := BuiltinMemoryModel(7, 64, AllocatorJemalloc)
+	base := *mm
+	mm.merge(&MemoryModel{Robj: 24, SharedIntegers: -1, SdsHeaders: []uint64{8}, Allocator: AllocatorLibc})
+	// the sizes which are zero are kept
+	assert.Equal(t, base.DictEntry, mm.DictEntry)
+	assert.Equal(t, base.Dict, mm.Dict)
+	assert.Equal(t, base.EmbstrLimit, mm.EmbstrLimit)
+	assert.Equal(t, base.SizeClasses, mm.SizeClasses)
+	assert.Equal(t, uint64(24), mm.Robj)
+	assert.Equal(t, int64(-1), mm.SharedIntegers)
+	assert.Equal(t, []uint64{8}, mm.SdsHeaders)
+	assert.Equal(t, AllocatorLibc, mm.Allocator)
+
+	mm.merge(&MemoryModel{})
+	assert.Equal(t, uint64(24), mm.Robj)
+}
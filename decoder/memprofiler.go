@@ -15,17 +15,15 @@
 package decoder
 
 import (
-	"math/rand"
+	"fmt"
+	"hash/fnv"
 	"sort"
 	"strconv"
+	"strings"
 )
 
 var (
 	skiplistMaxLevel    = 32
-	skiplistP           = 0.25
-	redisSharedInterges = int64(10000)
-	longSize            = uint64(8)
-	pointerSize         = uint64(8)
 	jemallocSizeClasses = []uint64{
 		8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 448, 512, 640, 768, 896, 1024,
 		1280, 1536, 1792, 2048, 2560, 3072, 3584, 4096, 5120, 6144, 7168, 8192, 10240, 12288, 14336, 16384, 20480, 24576,
@@ -58,15 +56,164 @@ var (
 	}
 )
 
-// MemProfiler get memory use for all kinds of data stuct
-type MemProfiler struct{}
+// MemProfiler get memory use for all kinds of data stuct, with the memory
+// model of the word size and version of redis given by the aux fields of the
+// rdb file, or the model set by SetMemoryModel
+type MemProfiler struct {
+	model MemoryModel
+	name  string
+
+	bits int
+	// version of redis as major*100+minor, 0 if unknown
+	version int
+	// profile and overrides set by SetMemoryModel
+	profile  string
+	override *MemoryModel
+	// maxmemory-policy of redis, empty if unknown
+	maxmemoryPolicy string
+}
+
+// NewMemProfiler return a MemProfiler of a 64 bits redis of unknown version
+func NewMemProfiler() MemProfiler {
+	m := MemProfiler{bits: 64}
+	m.configure()
+	return m
+}
+
+// SetBits set the word size of redis, from the redis-bits aux field
+func (m *MemProfiler) SetBits(bits int) error {
+	if bits != 32 && bits != 64 {
+		return fmt.Errorf("unknown redis-bits %d", bits)
+	}
+	m.bits = bits
+	m.configure()
+	return nil
+}
+
+// SetVersion set the version of redis, from the redis-ver aux field, e.g. 7.2.4
+func (m *MemProfiler) SetVersion(ver string) error {
+	parts := strings.SplitN(ver, ".", 3)
+	if len(parts) < 2 {
+		return fmt.Errorf("invalid redis-ver %q", ver)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return fmt.Errorf("invalid redis-ver %q", ver)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return fmt.Errorf("invalid redis-ver %q", ver)
+	}
+	m.version = major*100 + minor
+	m.configure()
+	return nil
+}
+
+// SetMemoryModel set the built-in profile and the sizes of mm, which take
+// precedence over redis-ver and redis-bits
+func (m *MemProfiler) SetMemoryModel(mm *MemoryModel) error {
+	if mm.Profile != "" {
+		if _, _, err := ParseProfile(mm.Profile); err != nil {
+			return err
+		}
+	}
+	m.profile = mm.Profile
+	m.override = mm
+	m.configure()
+	return nil
+}
+
+// maxmemoryPolicies are the values of maxmemory-policy
+var maxmemoryPolicies = []string{
+	"noeviction", "allkeys-lru", "volatile-lru", "allkeys-lfu", "volatile-lfu",
+	"allkeys-random", "volatile-random", "volatile-ttl",
+}
+
+// SetMaxmemoryPolicy set the maxmemory-policy of redis, redis does not use
+// shared integers for values when maxmemory is set with a LRU or LFU policy
+func (m *MemProfiler) SetMaxmemoryPolicy(policy string) error {
+	for _, p := range maxmemoryPolicies {
+		if policy == p {
+			m.maxmemoryPolicy = policy
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown maxmemory-policy %q", policy)
+}
+
+// configure the model from the version, the word size and the model set
+func (m *MemProfiler) configure() {
+	major := m.version / 100
+	allocator := AllocatorJemalloc
+	if m.profile != "" {
+		// checked by SetMemoryModel
+		pmajor, palloc, _ := ParseProfile(m.profile)
+		if pmajor > 0 {
+			major = pmajor
+		}
+		allocator = palloc
+	}
+	if m.override != nil && m.override.Allocator != "" {
+		allocator = m.override.Allocator
+	}
+
+	m.model = *BuiltinMemoryModel(major, m.bits, allocator)
+	if major == 3 && m.version > 0 && !m.atLeast(3, 2) {
+		// sds types and EMBSTR of 44 bytes are since redis 3.2
+		m.model.SdsHeaders = []uint64{4 + 4}
+		m.model.EmbstrLimit = 39
+	}
+	m.name = allocator
+	if major > 0 {
+		m.name = fmt.Sprintf("redis%d-%s", major, allocator)
+	}
+	if m.bits != 64 {
+		m.name += fmt.Sprintf(" (%d bits)", m.bits)
+	}
+	if m.override != nil {
+		m.model.merge(m.override)
+		if !m.override.isProfile() {
+			m.name += " with overrides"
+		}
+	}
+}
+
+// GetMemoryModel return the name and the sizes of the model in use
+func (m *MemProfiler) GetMemoryModel() (string, MemoryModel) {
+	if m.maxmemoryPolicy != "" {
+		return m.name + ", maxmemory-policy " + m.maxmemoryPolicy, m.model
+	}
+	return m.name, m.model
+}
+
+// atLeast reports whether the version of redis is known and at least major.minor
+func (m *MemProfiler) atLeast(major, minor int) bool {
+	return m.version >= major*100+minor
+}
+
+// elemRobj reports whether the elements of hashtables, sets, skiplists and
+// linked lists are robj, which is the case before redis 4.0 (rdb version 8)
+func (m *MemProfiler) elemRobj(rdbVer int) bool {
+	if m.version > 0 {
+		return !m.atLeast(4, 0)
+	}
+	return rdbVer < 8
+}
 
 // mallocOverhead used memory
 func (m *MemProfiler) mallocOverhead(size uint64) uint64 {
-	idx := sort.Search(len(jemallocSizeClasses),
-		func(i int) bool { return jemallocSizeClasses[i] >= size })
-	if idx < len(jemallocSizeClasses) {
-		return jemallocSizeClasses[idx]
+	if m.model.Allocator == AllocatorLibc {
+		chunk := alignTo(size+m.model.MallocHeader, m.model.MallocAlign)
+		if chunk < m.model.MallocMin {
+			return m.model.MallocMin
+		}
+		return chunk
+	}
+	classes := m.model.SizeClasses
+	idx := sort.Search(len(classes),
+		func(i int) bool { return classes[i] >= size })
+	if idx < len(classes) {
+		return classes[idx]
 	}
 	return size
 }
@@ -75,13 +222,33 @@ func (m *MemProfiler) mallocOverhead(size uint64) uint64 {
 // Each top level object is an entry in a dictionary, and so we have to include
 // the overhead of a dictionary entry
 func (m *MemProfiler) TopLevelObjOverhead(key []byte, expiry int64) uint64 {
-	return m.HashtableEntryOverhead() + m.SizeofString(key) + m.RobjOverhead() + m.KeyExpiryOverhead(expiry)
+	return m.TopLevelKeyOverhead(key, expiry) + m.RobjOverhead()
+}
+
+// KeyspaceOverhead get memory use of the main dict or the expires dict of a
+// database of size keys, the entries are counted by TopLevelObjOverhead.
+// Unlike HashtableOverhead there is a single table, as the dicts are sized
+// by RESIZEDB before the keys are loaded and do not rehash meanwhile.
+func (m *MemProfiler) KeyspaceOverhead(size uint64) uint64 {
+	if size == 0 {
+		return 0
+	}
+	return m.model.Dict + nextPower(size)*m.model.PointerSize
+}
+
+// TopLevelKeyOverhead get memory use of a top level key without its value,
+// the key is a sds in the dictionary entry
+func (m *MemProfiler) TopLevelKeyOverhead(key []byte, expiry int64) uint64 {
+	return m.HashtableEntryOverhead() + m.SizeofString(key) + m.KeyExpiryOverhead(expiry)
 }
 
 // HashtableOverhead get memory use of a hashtable
 // See  https://github.com/antirez/redis/blob/unstable/src/dict.h
 // See the structures dict and dictht
 // 2 * (3 unsigned longs + 1 pointer) + int + long + 2 pointers
+// Since redis 7.0 dictht is merged into dict
+// 3 pointers + 2 unsigned longs + long + int16 + 2 chars
+// The dict itself is Dict of the memory model
 //
 // Additionally, see **table in dictht
 // The length of the table is the next power of 2
@@ -90,7 +257,7 @@ func (m *MemProfiler) TopLevelObjOverhead(key []byte, expiry int64) uint64 {
 // case in which both tables are allocated, and so multiply
 // the size of **table by 1.5
 func (m *MemProfiler) HashtableOverhead(size uint64) uint64 {
-	return 4 + 7*longSize + 4*pointerSize + nextPower(size)*pointerSize*3/2
+	return m.model.Dict + nextPower(size)*m.model.PointerSize*3/2
 }
 
 func (m *MemProfiler) SizeofStreamRadixTree(numElements uint64) uint64 {
@@ -99,20 +266,20 @@ func (m *MemProfiler) SizeofStreamRadixTree(numElements uint64) uint64 {
 }
 
 func (m *MemProfiler) StreamOverhead() uint64 {
-	return 2 * pointerSize + 8 + 16 + // stream struct
-		pointerSize + 8 * 2 // rax struct
+	return 2 * m.model.PointerSize + 8 + 16 + // stream struct
+		m.model.PointerSize + 8 * 2 // rax struct
 }
 
 func (m *MemProfiler) StreamConsumer(name []byte) uint64 {
-	return pointerSize * 2 + 8 + m.SizeofString(name)
+	return m.model.PointerSize * 2 + 8 + m.SizeofString(name)
 }
 
 func (m *MemProfiler) StreamCG() uint64 {
-	return pointerSize * 2 + 16
+	return m.model.PointerSize * 2 + 16
 }
 
 func (m *MemProfiler) StreamNACK(length uint64) uint64 {
-	return length * (pointerSize + 8 + 8)
+	return length * (m.model.PointerSize + 8 + 8)
 }
 
 // HashtableEntryOverhead get memory use of hashtable entry
@@ -129,37 +296,52 @@ func (m *MemProfiler) StreamNACK(length uint64) uint64 {
 //     struct dictEntry *next;
 // } dictEntry;
 func (m *MemProfiler) HashtableEntryOverhead() uint64 {
-	return 3 * pointerSize
+	return m.model.DictEntry
 }
 
 // LinkedlistOverhead get memory use of a linked list
 // See https://github.com/antirez/redis/blob/unstable/src/adlist.h
 // A list has 5 pointers + an unsigned long
 func (m *MemProfiler) LinkedlistOverhead() uint64 {
-	return longSize + 5*pointerSize
+	return m.model.List
 }
 
 // LinkedListEntryOverhead get memory use of a linked list entry
 // See https://github.com/antirez/redis/blob/unstable/src/adlist.h
 // A node has 3 pointers
 func (m *MemProfiler) LinkedListEntryOverhead() uint64 {
-	return 3 * pointerSize
+	return m.model.ListNode
 }
 
 // SkiplistOverhead get memory use of a skiplist
+// A zset has a dict and a zskiplist of 2 pointers + an unsigned long + an int
 func (m *MemProfiler) SkiplistOverhead(size uint64) uint64 {
-	return 2*pointerSize + m.HashtableOverhead(size) + (2*pointerSize + 16)
+	return m.model.Zset + m.HashtableOverhead(size)
 }
 
-// SkiplistEntryOverhead get memory use of a skiplist entry
-func (m *MemProfiler) SkiplistEntryOverhead() uint64 {
-	return m.HashtableEntryOverhead() + 2*pointerSize + 8 + (pointerSize+8)*zsetRandLevel()
+// SkiplistEntryOverhead get memory use of the entry of member in the skiplist
+// of key
+// A node has 2 pointers + a double, and a pointer + an unsigned long per level
+func (m *MemProfiler) SkiplistEntryOverhead(key, member []byte) uint64 {
+	return m.HashtableEntryOverhead() + m.model.SkiplistNode + m.model.SkiplistLevel*zsetRandLevel(key, member)
 }
 
+// QuicklistOverhead get memory use of a quicklist of size nodes
+// See https://github.com/antirez/redis/blob/unstable/src/quicklist.h
+// A quicklist has 2 pointers + unsigned long count + unsigned long len + bit
+// fields, len is an unsigned int before redis 4.0
+// A node has 3 pointers + sz + 32 bits of bit fields, sz is an unsigned int
+// before redis 7.0 and a size_t since
 func (m *MemProfiler) QuicklistOverhead(size uint64) uint64 {
-	quicklist := 2 * pointerSize + 8 + 2 * 4
-	quickitem := 4 * pointerSize + 8 + 2 * 4
-	return quicklist + size * quickitem
+	return m.model.Quicklist + size*m.model.QuicklistNode
+}
+
+// QuicklistLZFOverhead get memory use of a LZF compressed quicklist node of
+// size compressed bytes, which replaces its ziplist or listpack
+// See https://github.com/antirez/redis/blob/unstable/src/quicklist.h
+// A quicklistLZF has an unsigned int sz + the compressed bytes
+func (m *MemProfiler) QuicklistLZFOverhead(size uint64) uint64 {
+	return 4 + size
 }
 
 func (m *MemProfiler) ZiplistHeaderOverhead() uint64 {
@@ -170,7 +352,9 @@ func (m *MemProfiler) ZiplistEntryOverhead(value []byte) uint64 {
 	header := 0
 	size := 0
 
-	if n, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+	// redis only stores integers of the canonical form as integers, not
+	// "+5", "007" or "-0"
+	if n, ok := redisInt(value); ok {
 		header = 1
 		switch {
 		case n < 12: size = 0
@@ -199,6 +383,63 @@ func (m *MemProfiler) ZiplistEntryOverhead(value []byte) uint64 {
 	return uint64(header + size)
 }
 
+// ListpackHeaderOverhead get memory use of a listpack header
+// See https://github.com/antirez/listpack/blob/master/listpack.md
+// 4 bytes total bytes + 2 bytes num elements + 1 byte end of listpack
+func (m *MemProfiler) ListpackHeaderOverhead() uint64 {
+	return 4 + 2 + 1
+}
+
+// ListpackEntryOverhead get memory use of a listpack entry
+// Each entry is encoding-type + element-data + element-tot-len(backlen)
+func (m *MemProfiler) ListpackEntryOverhead(value []byte) uint64 {
+	size := 0
+
+	// redis only stores integers of the canonical form as integers, not
+	// "+5", "007" or "-0"
+	if n, ok := redisInt(value); ok {
+		switch {
+		case n >= 0 && n <= 127:
+			size = 1
+		case n >= -4096 && n <= 4095:
+			size = 2
+		case n >= -32768 && n <= 32767:
+			size = 3
+		case n >= -8388608 && n <= 8388607:
+			size = 4
+		case n >= -2147483648 && n <= 2147483647:
+			size = 5
+		default:
+			size = 9
+		}
+	} else {
+		size = len(value)
+		if size <= 63 {
+			size++
+		} else if size <= 4095 {
+			size += 2
+		} else {
+			size += 5
+		}
+	}
+
+	backlen := 0
+	switch {
+	case size <= 127:
+		backlen = 1
+	case size < 16383:
+		backlen = 2
+	case size < 2097151:
+		backlen = 3
+	case size < 268435455:
+		backlen = 4
+	default:
+		backlen = 5
+	}
+
+	return uint64(size + backlen)
+}
+
 // KeyExpiryOverhead get memory useage of a key expiry
 // Key expiry is stored in a hashtable, so we have to pay for the cost of a hashtable entry
 // The timestamp itself is stored as an int64, which is a 8 bytes
@@ -218,25 +459,119 @@ func (m *MemProfiler) KeyExpiryOverhead(expiry int64) uint64 {
 //     int refcount;
 //     void *ptr;
 // } robj;
-const LRU_BITS = 24
-
+// The bit fields take 4 bytes
 func (m *MemProfiler) RobjOverhead() uint64 {
-	return pointerSize + 4 + 4 + LRU_BITS + 4
+	return m.model.Robj
 }
 
-// SizeofString get memory use of a string
+// SizeofString get memory use of a sds string
 // https://github.com/antirez/redis/blob/unstable/src/sds.h
+// Since redis 3.2 the header is sdshdr5, 8, 16, 32 or 64 by the length, an
+// empty sds is a sdshdr8
 func (m *MemProfiler) SizeofString(bytes []byte) uint64 {
-	str := string(bytes)
-	num, err := strconv.ParseInt(str, 10, 64)
-	if err == nil {
-		if num < redisSharedInterges && num > 0 {
+	l := uint64(len(bytes))
+	return m.mallocOverhead(m.sdsHeader(l, l > 0) + l + 1)
+}
+
+// sdsHeader get the header size of a sds of length l, sdshdr5 is only used
+// if type5
+func (m *MemProfiler) sdsHeader(l uint64, type5 bool) uint64 {
+	headers := m.model.SdsHeaders
+	if len(headers) == 1 {
+		return headers[0]
+	}
+	// sdshdr5, 8, 16, 32, 64
+	limits := []uint64{1 << 5, 1 << 8, 1 << 16, 1 << 32}
+	i := 0
+	if !type5 {
+		i = 1
+	}
+	for ; i < len(headers)-1 && i < len(limits) && l >= limits[i]; i++ {
+	}
+	return headers[i]
+}
+
+// StringObjOverhead get memory use of the robj of a string value and the
+// string. Values which are integers are encoded in the robj, those from 0 to
+// SharedIntegers are shared objects unless the maxmemory policy is LRU or LFU.
+// Short strings are EMBSTR, embedded in the same allocation as the robj with
+// a sdshdr8.
+// https://github.com/antirez/redis/blob/unstable/src/object.c
+func (m *MemProfiler) StringObjOverhead(value []byte) uint64 {
+	if num, ok := redisInt(value); ok {
+		if m.sharedIntegers() && num >= 0 && num < m.model.SharedIntegers {
 			return 0
 		}
-		return 8
+		return m.mallocOverhead(m.RobjOverhead())
+	}
+	l := uint64(len(value))
+	if m.model.EmbstrLimit >= 0 && l <= uint64(m.model.EmbstrLimit) {
+		return m.mallocOverhead(m.RobjOverhead() + m.sdsHeader(l, false) + l + 1)
+	}
+	return m.mallocOverhead(m.RobjOverhead()) + m.SizeofString(value)
+}
+
+// StringEncoding get the encoding of a string value, int, embstr or raw
+func (m *MemProfiler) StringEncoding(value []byte) string {
+	if _, ok := redisInt(value); ok {
+		return "int"
+	}
+	if m.model.EmbstrLimit >= 0 && uint64(len(value)) <= uint64(m.model.EmbstrLimit) {
+		return "embstr"
 	}
-	l := uint64(len(str))
-	return m.mallocOverhead(l + 8 + 1)
+	return "raw"
+}
+
+// NumericStringOverhead get memory use of the robj of a string value which
+// is a decimal number not encoded as an integer, such as 3.25 or 0012, if its
+// significant digits were stored as an integer, or packed in binary if they
+// do not fit in a long. ok is false for other values.
+func (m *MemProfiler) NumericStringOverhead(value []byte) (size uint64, ok bool) {
+	if _, isInt := redisInt(value); isInt || len(value) > 64 {
+		return 0, false
+	}
+	s := strings.TrimLeft(string(value), "+-")
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s = s[:i] + s[i+1:]
+	}
+	if s == "" || strings.Trim(s, "0123456789") != "" {
+		return 0, false
+	}
+	if digits := strings.TrimLeft(s, "0"); len(digits) > 18 {
+		// log2(10) bits per digit and a byte of sign and scale
+		packed := make([]byte, (len(digits)*3322/1000+7)/8+1)
+		for i := range packed {
+			packed[i] = 'x'
+		}
+		return m.StringObjOverhead(packed), true
+	}
+	num, _ := strconv.ParseInt(s, 10, 64)
+	return m.StringObjOverhead([]byte(strconv.FormatInt(num, 10))), true
+}
+
+// sharedIntegers reports whether string values use the shared integers
+func (m *MemProfiler) sharedIntegers() bool {
+	if m.model.SharedIntegers <= 0 {
+		return false
+	}
+	switch m.maxmemoryPolicy {
+	case "allkeys-lru", "volatile-lru", "allkeys-lfu", "volatile-lfu":
+		return false
+	}
+	return true
+}
+
+// redisInt parse s as redis does to encode a string as integer, it must be
+// a long without leading zeros and plus sign
+func redisInt(s []byte) (int64, bool) {
+	if len(s) == 0 || len(s) > 20 {
+		return 0, false
+	}
+	num, err := strconv.ParseInt(string(s), 10, 64)
+	if err != nil || strconv.FormatInt(num, 10) != string(s) {
+		return 0, false
+	}
+	return num, true
 }
 
 // ElemLen get length of a element
@@ -252,6 +587,11 @@ func (m *MemProfiler) ElemLen(element []byte) uint64 {
 	return uint64(len(element))
 }
 
+// alignTo round size up to a multiple of align
+func alignTo(size, align uint64) uint64 {
+	return (size + align - 1) / align * align
+}
+
 func nextPower(size uint64) uint64 {
 	power := uint64(1)
 	for power <= size {
@@ -260,15 +600,25 @@ func nextPower(size uint64) uint64 {
 	return power
 }
 
-func zsetRandLevel() uint64 {
+// zsetRandLevel get the level of a skiplist node as zslRandomLevel does, with
+// the random numbers taken from a hash of the key and the member instead of
+// rand, so that the estimate is the same on each run. A level is added with
+// probability ZSKIPLIST_P = 1/4, i.e. for each pair of zero bits of the hash.
+func zsetRandLevel(key, member []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(key)
+	h.Write([]byte{0})
+	h.Write(member)
+	// the low bits of fnv depend on the low bits of the input only, mix them
+	// with the finalizer of splitmix64
+	rint := h.Sum64()
+	rint = (rint ^ rint>>30) * 0xbf58476d1ce4e5b9
+	rint = (rint ^ rint>>27) * 0x94d049bb133111eb
+	rint ^= rint >> 31
 	level := 1
-	rint := rand.Intn(0xFFFF)
-	for rint < int(0xFFFF*1/4) { //skiplistP
+	for rint&3 == 0 && level < skiplistMaxLevel {
 		level++
-		rint = rand.Intn(0xFFFF)
-	}
-	if level < skiplistMaxLevel {
-		return uint64(level)
+		rint >>= 2
 	}
-	return uint64(skiplistMaxLevel)
+	return uint64(level)
 }
@@ -0,0 +1,119 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decoder
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/dongmx/rdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeZSet(key string, n int) uint64 {
+	d := NewDecoder()
+	d.StartZSet([]byte(key), int64(n), 0, &rdb.Info{Encoding: "skiplist"})
+	for i := 0; i < n; i++ {
+		d.Zadd([]byte(key), float64(i), []byte("member:"+strconv.Itoa(i)))
+	}
+	d.EndZSet([]byte(key))
+	return (<-d.Entries).Bytes
+}
+
+func TestSkiplistEstimateIsReproducible(t *testing.T) {
+	bytes := decodeZSet("zset:1", 1000)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, bytes, decodeZSet("zset:1", 1000))
+	}
+	// locks the estimate, it changes only with the memory model
+	assert.Equal(t, uint64(121148), bytes)
+}
+
+func TestZsetRandLevel(t *testing.T) {
+	n := 100000
+	levels := uint64(0)
+	for i := 0; i < n; i++ {
+		level := zsetRandLevel([]byte("zset"), []byte(strconv.Itoa(i)))
+		assert.True(t, level >= 1 && level <= uint64(skiplistMaxLevel))
+		levels += level
+	}
+	// the expected level is 1/(1-p) = 4/3
+	assert.InDelta(t, 4.0/3, float64(levels)/float64(n), 0.01)
+}
+
+func TestListpackEntryOverhead(t *testing.T) {
+	m := NewMemProfiler()
+	assert.Equal(t, uint64(2), m.ListpackEntryOverhead([]byte("5")))
+	assert.Equal(t, uint64(3), m.ListpackEntryOverhead([]byte("-5")))
+	// integers not of the canonical form are strings
+	assert.Equal(t, m.ListpackEntryOverhead([]byte("ab")), m.ListpackEntryOverhead([]byte("+5")))
+	assert.Equal(t, m.ListpackEntryOverhead([]byte("ab")), m.ListpackEntryOverhead([]byte("-0")))
+	assert.Equal(t, m.ListpackEntryOverhead([]byte("abc")), m.ListpackEntryOverhead([]byte("007")))
+}
+
+func TestSdsHeader(t *testing.T) {
+	m := NewMemProfiler()
+	for _, c := range []struct {
+		l      uint64
+		header uint64
+	}{{31, 1}, {32, 3}, {255, 3}, {256, 5}, {65535, 5}, {65536, 9}, {1<<32 - 1, 9}, {1 << 32, 17}} {
+		assert.Equal(t, c.header, m.sdsHeader(c.l, true), "length %d", c.l)
+	}
+	// an empty sds and the sds of an EMBSTR are sdshdr8 at least
+	assert.Equal(t, uint64(3), m.sdsHeader(0, false))
+	assert.Equal(t, uint64(3), m.sdsHeader(31, false))
+	// sdshdr5 + 31 + 1 and sdshdr8 + 32 + 1 are in the size class of 40
+	assert.Equal(t, uint64(40), m.SizeofString(make([]byte, 31)))
+	assert.Equal(t, uint64(40), m.SizeofString(make([]byte, 32)))
+
+	m.SetVersion("3.0.7")
+	assert.Equal(t, uint64(8), m.sdsHeader(31, true))
+	assert.Equal(t, uint64(8), m.sdsHeader(1<<32, true))
+}
+
+func TestStringObjOverhead(t *testing.T) {
+	m := NewMemProfiler()
+	str := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = 'x'
+		}
+		return b
+	}
+	// robj + sdshdr8 + 44 + 1 in one allocation of 64
+	assert.Equal(t, "embstr", m.StringEncoding(str(44)))
+	assert.Equal(t, uint64(64), m.StringObjOverhead(str(44)))
+	// robj and sdshdr8 + 45 + 1
+	assert.Equal(t, "raw", m.StringEncoding(str(45)))
+	assert.Equal(t, uint64(16+56), m.StringObjOverhead(str(45)))
+
+	m.SetVersion("3.0.7")
+	assert.Equal(t, "embstr", m.StringEncoding(str(39)))
+	assert.Equal(t, "raw", m.StringEncoding(str(40)))
+
+	// shared integers unless the policy is LRU or LFU
+	m = NewMemProfiler()
+	assert.Equal(t, "int", m.StringEncoding([]byte("9999")))
+	assert.Equal(t, uint64(0), m.StringObjOverhead([]byte("9999")))
+	assert.Equal(t, uint64(16), m.StringObjOverhead([]byte("10000")))
+	assert.NoError(t, m.SetMaxmemoryPolicy("volatile-ttl"))
+	assert.Equal(t, uint64(0), m.StringObjOverhead([]byte("9999")))
+	for _, policy := range []string{"allkeys-lru", "volatile-lfu"} {
+		assert.NoError(t, m.SetMaxmemoryPolicy(policy))
+		assert.Equal(t, uint64(16), m.StringObjOverhead([]byte("9999")), policy)
+		assert.Equal(t, uint64(16), m.StringObjOverhead([]byte("10000")), policy)
+	}
+	assert.Error(t, m.SetMaxmemoryPolicy("lru"))
+}
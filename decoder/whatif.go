@@ -0,0 +1,358 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decoder
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dongmx/rdb"
+)
+
+// RedisConfig is the encoding thresholds of redis.conf, the memory use of
+// keys is estimated once more as if they applied
+type RedisConfig struct {
+	HashMaxListpackEntries int64
+	HashMaxListpackValue   int64
+	ZsetMaxListpackEntries int64
+	ZsetMaxListpackValue   int64
+	SetMaxIntsetEntries    int64
+	// sets are listpacks since redis 7.2
+	SetMaxListpackEntries int64
+	SetMaxListpackValue   int64
+	// ListMaxListpackSize is the entries of a quicklist node if positive,
+	// or its size from -1 for 4kb to -5 for 64kb
+	ListMaxListpackSize int64
+	ListCompressDepth   int64
+}
+
+// DefaultRedisConfig return the defaults of redis.conf
+func DefaultRedisConfig() *RedisConfig {
+	return &RedisConfig{
+		HashMaxListpackEntries: 128,
+		HashMaxListpackValue:   64,
+		ZsetMaxListpackEntries: 128,
+		ZsetMaxListpackValue:   64,
+		SetMaxIntsetEntries:    512,
+		SetMaxListpackEntries:  128,
+		SetMaxListpackValue:    64,
+		ListMaxListpackSize:    -2,
+		ListCompressDepth:      0,
+	}
+}
+
+// LoadRedisConfig read the encoding thresholds of a redis.conf, the other
+// directives are ignored and the thresholds not in the file are defaults.
+// The ziplist directives before redis 7 are aliases of the listpack ones.
+func LoadRedisConfig(path string) (*RedisConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf := DefaultRedisConfig()
+	directives := map[string]*int64{
+		"hash-max-ziplist-entries":  &conf.HashMaxListpackEntries,
+		"hash-max-listpack-entries": &conf.HashMaxListpackEntries,
+		"hash-max-ziplist-value":    &conf.HashMaxListpackValue,
+		"hash-max-listpack-value":   &conf.HashMaxListpackValue,
+		"zset-max-ziplist-entries":  &conf.ZsetMaxListpackEntries,
+		"zset-max-listpack-entries": &conf.ZsetMaxListpackEntries,
+		"zset-max-ziplist-value":    &conf.ZsetMaxListpackValue,
+		"zset-max-listpack-value":   &conf.ZsetMaxListpackValue,
+		"set-max-intset-entries":    &conf.SetMaxIntsetEntries,
+		"set-max-listpack-entries":  &conf.SetMaxListpackEntries,
+		"set-max-listpack-value":    &conf.SetMaxListpackValue,
+		"list-max-ziplist-size":     &conf.ListMaxListpackSize,
+		"list-max-listpack-size":    &conf.ListMaxListpackSize,
+		"list-compress-depth":       &conf.ListCompressDepth,
+	}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		v, ok := directives[strings.ToLower(fields[0])]
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseInt(strings.Trim(fields[1], `"'`), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid %s %q", path, n, fields[0], fields[1])
+		}
+		*v = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if conf.ListMaxListpackSize == 0 || conf.ListMaxListpackSize < -5 {
+		return nil, fmt.Errorf("%s: invalid list-max-ziplist-size %d", path, conf.ListMaxListpackSize)
+	}
+	return conf, nil
+}
+
+// whatIfElems is the elements of the current entry, with the memory use of
+// both the compact and the large encoding
+type whatIfElems struct {
+	typ       string
+	n         uint64
+	listpack  uint64
+	hashtable uint64
+	maxLen    uint64
+	// members of a set are integers of intWidth bytes
+	ints     bool
+	intWidth uint64
+}
+
+// SetRedisConfig estimate the memory use of keys once more as if conf
+// applied, as the WhatIfBytes of entries
+func (d *Decoder) SetRedisConfig(conf *RedisConfig) {
+	d.whatIf = conf
+}
+
+// GetRedisConfig return the config of what-if estimates, nil if none
+func (d *Decoder) GetRedisConfig() *RedisConfig {
+	return d.whatIf
+}
+
+// whatIfStart start to collect the elements of a key of typ
+func (d *Decoder) whatIfStart(typ string) {
+	d.elems = whatIfElems{typ: typ, ints: true}
+}
+
+// compactEntry get memory use of value in a listpack, or a ziplist before
+// redis 7.0
+func (d *Decoder) compactEntry(value []byte) uint64 {
+	if d.listpack() {
+		return d.m.ListpackEntryOverhead(value)
+	}
+	return d.m.ZiplistEntryOverhead(value)
+}
+
+func (d *Decoder) compactHeader() uint64 {
+	if d.listpack() {
+		return d.m.ListpackHeaderOverhead()
+	}
+	return d.m.ZiplistHeaderOverhead()
+}
+
+func (d *Decoder) listpack() bool {
+	if d.m.version > 0 {
+		return d.m.atLeast(7, 0)
+	}
+	return d.rdbVer >= 10
+}
+
+// whatIfElem count the length of an element
+func (d *Decoder) whatIfElem(elem []byte) {
+	if l := uint64(len(elem)); l > d.elems.maxLen {
+		d.elems.maxLen = l
+	}
+}
+
+func (d *Decoder) whatIfHset(field, value []byte) {
+	if d.whatIf == nil {
+		return
+	}
+	d.elems.n++
+	d.whatIfElem(field)
+	d.whatIfElem(value)
+	d.elems.listpack += d.compactEntry(field) + d.compactEntry(value)
+	d.elems.hashtable += d.m.SizeofString(field) + d.m.SizeofString(value) + d.m.HashtableEntryOverhead()
+	if d.m.elemRobj(d.rdbVer) {
+		d.elems.hashtable += 2 * d.m.RobjOverhead()
+	}
+}
+
+func (d *Decoder) whatIfSadd(member []byte) {
+	if d.whatIf == nil {
+		return
+	}
+	d.elems.n++
+	d.whatIfElem(member)
+	d.elems.listpack += d.compactEntry(member)
+	d.elems.hashtable += d.m.SizeofString(member) + d.m.HashtableEntryOverhead()
+	if d.m.elemRobj(d.rdbVer) {
+		d.elems.hashtable += d.m.RobjOverhead()
+	}
+	num, ok := redisInt(member)
+	if !ok {
+		d.elems.ints = false
+		return
+	}
+	if width := intWidth(num); width > d.elems.intWidth {
+		d.elems.intWidth = width
+	}
+}
+
+// intWidth get the bytes of num in an intset
+func intWidth(num int64) uint64 {
+	if num >= math.MinInt16 && num <= math.MaxInt16 {
+		return 2
+	}
+	if num >= math.MinInt32 && num <= math.MaxInt32 {
+		return 4
+	}
+	return 8
+}
+
+func (d *Decoder) whatIfZadd(key []byte, score float64, member []byte) {
+	if d.whatIf == nil {
+		return
+	}
+	d.elems.n++
+	d.whatIfElem(member)
+	d.elems.listpack += d.compactEntry(member) + d.compactEntry([]byte(formatScore(score)))
+	d.elems.hashtable += 8 + d.m.SizeofString(member) + d.m.SkiplistEntryOverhead(key, member)
+	if d.m.elemRobj(d.rdbVer) {
+		d.elems.hashtable += d.m.RobjOverhead()
+	}
+}
+
+// formatScore format a score as it is saved in a listpack
+func formatScore(score float64) string {
+	if score == math.Trunc(score) && math.Abs(score) < 1<<53 {
+		return strconv.FormatInt(int64(score), 10)
+	}
+	return strconv.FormatFloat(score, 'g', 17, 64)
+}
+
+func (d *Decoder) whatIfRpush(value []byte) {
+	if d.whatIf == nil {
+		return
+	}
+	d.elems.n++
+	d.elems.listpack += d.compactEntry(value)
+}
+
+// whatIfBytes get memory use of e as if the redis config of what-if applied
+// and the encoding it would have, the estimate of e is kept if its encoding
+// would be the same
+func (d *Decoder) whatIfBytes(e *Entry) (uint64, string) {
+	conf := d.whatIf
+	elems := d.elems
+	bytes := d.m.TopLevelObjOverhead([]byte(e.Key), e.Expiry)
+	compact := d.compactHeader() + elems.listpack
+	switch elems.typ {
+	case "hash":
+		if int64(elems.n) <= conf.HashMaxListpackEntries && int64(elems.maxLen) <= conf.HashMaxListpackValue {
+			return d.keep(e, d.compactEncoding(), bytes+compact)
+		}
+		return d.keep(e, "hashtable", bytes+d.m.HashtableOverhead(elems.n)+elems.hashtable)
+	case "set":
+		if elems.ints && int64(elems.n) <= conf.SetMaxIntsetEntries {
+			// encoding + length + contents
+			return d.keep(e, "intset", bytes+4+4+elems.n*elems.intWidth)
+		}
+		if d.setListpack() && int64(elems.n) <= conf.SetMaxListpackEntries && int64(elems.maxLen) <= conf.SetMaxListpackValue {
+			return d.keep(e, d.compactEncoding(), bytes+compact)
+		}
+		return d.keep(e, "hashtable", bytes+d.m.HashtableOverhead(elems.n)+elems.hashtable)
+	case "sortedset":
+		if int64(elems.n) <= conf.ZsetMaxListpackEntries && int64(elems.maxLen) <= conf.ZsetMaxListpackValue {
+			return d.keep(e, d.compactEncoding(), bytes+compact)
+		}
+		return d.keep(e, "skiplist", bytes+d.m.SkiplistOverhead(elems.n)+elems.hashtable)
+	case "list":
+		nodes := quicklistNodes(elems.n, elems.listpack, conf.ListMaxListpackSize)
+		if e.Encoding == "quicklist" && nodes == d.currentInfo.Zips && conf.ListCompressDepth == d.listCompressDepth {
+			return e.Bytes, e.Encoding
+		}
+		bytes += d.m.QuicklistOverhead(nodes) + nodes*d.compactHeader() + elems.listpack
+		if nodes == 0 {
+			return bytes, "quicklist"
+		}
+		return d.whatIfCompress(bytes, nodes, d.compactHeader()+elems.listpack/nodes), "quicklist"
+	}
+	return e.Bytes, e.Encoding
+}
+
+// whatIfCompress get memory use bytes of a quicklist of nodes of size bytes
+// each with its interior nodes compressed by the sampled ratio
+func (d *Decoder) whatIfCompress(bytes, nodes, size uint64) uint64 {
+	if d.whatIf.ListCompressDepth <= 0 || uint64(2*d.whatIf.ListCompressDepth) >= nodes {
+		return bytes
+	}
+	depth := uint64(d.whatIf.ListCompressDepth)
+	compressed, ok := d.lzf.compress(rdb.QuicklistNode{Size: int(size)})
+	if !ok {
+		return bytes
+	}
+	interior := nodes - 2*depth
+	return bytes - interior*size + interior*d.m.QuicklistLZFOverhead(compressed)
+}
+
+// compactEncodings are the encodings of a listpack or its predecessors
+const compactEncodings = "ziplist listpack zipmap"
+
+// compactEncoding get the encoding of small keys, listpack since redis 7.0
+func (d *Decoder) compactEncoding() string {
+	if d.listpack() {
+		return "listpack"
+	}
+	return "ziplist"
+}
+
+// keep return the estimate of e and its encoding if it would be encoded as
+// encoding, or else bytes and encoding
+func (d *Decoder) keep(e *Entry, encoding string, bytes uint64) (uint64, string) {
+	if e.Encoding == encoding || isCompact(e.Encoding) && isCompact(encoding) {
+		return e.Bytes, e.Encoding
+	}
+	return bytes, encoding
+}
+
+func isCompact(encoding string) bool {
+	for _, enc := range strings.Fields(compactEncodings) {
+		if encoding == enc {
+			return true
+		}
+	}
+	return false
+}
+
+// setListpack reports whether sets can be listpacks, since redis 7.2
+func (d *Decoder) setListpack() bool {
+	if d.m.version > 0 {
+		return d.m.atLeast(7, 2)
+	}
+	return d.rdbVer >= 11
+}
+
+// quicklistNodes get the number of nodes of a quicklist of n entries of
+// size bytes, with nodes of at most fill entries if fill is positive, or
+// 4kb to 64kb for fill -1 to -5
+func quicklistNodes(n, size uint64, fill int64) uint64 {
+	if n == 0 {
+		return 0
+	}
+	var nodes uint64
+	if fill > 0 {
+		nodes = (n + uint64(fill) - 1) / uint64(fill)
+	} else {
+		limit := uint64(4096) << uint(-fill-1)
+		nodes = (size + limit - 1) / limit
+	}
+	if nodes == 0 {
+		nodes = 1
+	}
+	return nodes
+}
@@ -0,0 +1,100 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decoder
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/dongmx/rdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeHash(conf *RedisConfig, n int) *Entry {
+	d := NewDecoder()
+	d.SetRedisConfig(conf)
+	key := []byte("hash:1")
+	d.StartHash(key, int64(n), 0, &rdb.Info{Encoding: "hashtable"})
+	for i := 0; i < n; i++ {
+		d.Hset(key, []byte("field:"+strconv.Itoa(i)), []byte(strconv.Itoa(i)))
+	}
+	d.EndHash(key)
+	return <-d.Entries
+}
+
+func TestWhatIfHashEncoding(t *testing.T) {
+	e := decodeHash(DefaultRedisConfig(), 200)
+	assert.Equal(t, e.Bytes, e.WhatIfBytes)
+	assert.Equal(t, "hashtable", e.WhatIfEncoding)
+
+	conf := DefaultRedisConfig()
+	conf.HashMaxListpackEntries = 256
+	e = decodeHash(conf, 200)
+	assert.True(t, e.WhatIfBytes < e.Bytes/2)
+	assert.Equal(t, "ziplist", e.WhatIfEncoding)
+}
+
+func TestLoadRedisConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "redis.conf")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("# comment\nhash-max-ziplist-entries 512\nZSET-MAX-LISTPACK-VALUE 32\nlist-max-ziplist-size -3\nmaxmemory 1gb\n")
+	f.Close()
+
+	conf, err := LoadRedisConfig(f.Name())
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(512), conf.HashMaxListpackEntries)
+		assert.Equal(t, int64(32), conf.ZsetMaxListpackValue)
+		assert.Equal(t, int64(-3), conf.ListMaxListpackSize)
+		assert.Equal(t, int64(512), conf.SetMaxIntsetEntries)
+	}
+	assert.Equal(t, uint64(5), quicklistNodes(100, 20000, -1))
+	assert.Equal(t, uint64(10), quicklistNodes(100, 20000, 10))
+}
+
+func decodeQuicklist(depth int64, nodes []rdb.QuicklistNode) *Entry {
+	d := NewDecoder()
+	d.SetListCompressDepth(depth)
+	key := []byte("queue:1")
+	info := &rdb.Info{Encoding: "quicklist", NodeEncoding: "listpack", Zips: uint64(len(nodes))}
+	d.StartList(key, -1, 0, info)
+	for _, node := range nodes {
+		info.Nodes = append(info.Nodes, node)
+		for i := 0; i < 10; i++ {
+			d.Rpush(key, []byte("item:"+strconv.Itoa(i)))
+		}
+	}
+	d.EndList(key)
+	return <-d.Entries
+}
+
+func TestCompressQuicklistNodes(t *testing.T) {
+	uncompressed := decodeQuicklist(0, []rdb.QuicklistNode{{Size: 97}, {Size: 97}, {Size: 97}, {Size: 97}}).Bytes
+	nodes := []rdb.QuicklistNode{{Size: 97, CompressedSize: 40}, {Size: 97, CompressedSize: 40}, {Size: 97}, {Size: 97, CompressedSize: 40}}
+	// the nodes saved compressed are a quicklistLZF of 4 + 40 bytes whatever
+	// the depth
+	assert.Equal(t, uncompressed-3*(97-44), decodeQuicklist(0, nodes).Bytes)
+	assert.Equal(t, uncompressed-3*(97-44), decodeQuicklist(2, nodes).Bytes)
+	// and the interior one saved uncompressed by the sampled ratio
+	assert.Equal(t, uncompressed-4*(97-44), decodeQuicklist(1, nodes).Bytes)
+
+	// nodes redis would not compress
+	small := []rdb.QuicklistNode{{Size: 40}, {Size: 40}, {Size: 40}}
+	assert.Equal(t, decodeQuicklist(0, small).Bytes, decodeQuicklist(1, small).Bytes)
+}
@@ -0,0 +1,326 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/urfave/cli"
+	"github.com/xueqiu/rdr/decoder"
+)
+
+// kinds of advice
+const (
+	adviceBucketStrings  = "bucket-strings"
+	adviceHashListpack   = "hash-listpack"
+	adviceZsetListpack   = "zset-listpack"
+	adviceIntset         = "intset"
+	adviceNumericStrings = "numeric-strings"
+	adviceLargeNoTTL     = "large-no-ttl"
+)
+
+// hashes and sorted sets of up to listpackFactor times the listpack
+// thresholds, and sets of integers of up to intsetFactor times the intset
+// threshold are advised to be converted
+const (
+	listpackFactor = 2
+	intsetFactor   = 8
+)
+
+// Advice is a recommendation to save memory of the keys of a prefix
+type Advice struct {
+	Kind   string
+	Type   string
+	Prefix string
+	// Num and Bytes are of the keys the advice applies to
+	Num   uint64
+	Bytes uint64
+	// Savings is the estimated bytes saved if the advice is taken
+	Savings uint64
+	Advice  string
+}
+
+type adviceKey struct {
+	Kind   string
+	Type   string
+	Prefix string
+}
+
+// adviceCount is the keys of a prefix an advice applies to
+type adviceCount struct {
+	num     uint64
+	bytes   uint64
+	savings uint64
+	// maxElems is the largest number of elements of the keys
+	maxElems uint64
+	// lengths of the hash fields and values of bucketed strings
+	fieldBytes uint64
+	valueBytes uint64
+}
+
+// advisor find memory optimizations of keys by prefix
+type advisor struct {
+	// conf is the encoding thresholds of the instance, the what-if
+	// estimates of entries must be of adviseConfig(conf)
+	conf *decoder.RedisConfig
+	// c is the counter of the keys, of which the key prefix options are
+	c *Counter
+	// string keys of a prefix are advised to be bucketed into hashes if
+	// there are minBucketKeys at least
+	minBucketKeys uint64
+	// keys without TTL of largeBytes at least are advised to expire
+	largeBytes uint64
+	counts     map[adviceKey]*adviceCount
+	advice     []*Advice
+}
+
+func newAdvisor(conf *decoder.RedisConfig, c *Counter) *advisor {
+	return &advisor{
+		conf:          conf,
+		c:             c,
+		minBucketKeys: 1000,
+		largeBytes:    1024 * 1024,
+		counts:        map[adviceKey]*adviceCount{},
+	}
+}
+
+// adviseConfig return the redis config of the what-if estimates of advise,
+// conf with the thresholds of hashes, sorted sets and intsets raised
+func adviseConfig(conf *decoder.RedisConfig) *decoder.RedisConfig {
+	c := *conf
+	c.HashMaxListpackEntries *= listpackFactor
+	c.ZsetMaxListpackEntries *= listpackFactor
+	c.SetMaxIntsetEntries *= intsetFactor
+	return &c
+}
+
+func (a *advisor) add(kind string, e *decoder.Entry, prefix string, savings uint64) *adviceCount {
+	key := adviceKey{Kind: kind, Type: e.Type, Prefix: prefix}
+	cnt, ok := a.counts[key]
+	if !ok {
+		cnt = &adviceCount{}
+		a.counts[key] = cnt
+	}
+	cnt.num++
+	cnt.bytes += e.Bytes
+	cnt.savings += savings
+	if e.NumOfElem > cnt.maxElems {
+		cnt.maxElems = e.NumOfElem
+	}
+	return cnt
+}
+
+func (a *advisor) count(e *decoder.Entry) {
+	prefix := keyPattern(a.c.normalizeKey(e.Key), a.c.separators, a.c.maxPrefixDepth)
+	if e.Expiry <= 0 && e.Bytes >= a.largeBytes {
+		a.add(adviceLargeNoTTL, e, prefix, e.Bytes)
+	}
+	// the encodings which the raised thresholds of adviseConfig would change
+	converted := e.WhatIfBytes > 0 && e.WhatIfBytes < e.Bytes
+	switch {
+	case e.Type == "hash" && e.Encoding == "hashtable" && converted:
+		a.add(adviceHashListpack, e, prefix, e.Bytes-e.WhatIfBytes)
+	case e.Type == "sortedset" && e.Encoding == "skiplist" && converted:
+		a.add(adviceZsetListpack, e, prefix, e.Bytes-e.WhatIfBytes)
+	case e.Type == "set" && e.Encoding != "intset" && e.WhatIfEncoding == "intset" && converted:
+		a.add(adviceIntset, e, prefix, e.Bytes-e.WhatIfBytes)
+	case e.Type == "string" && e.NumericBytes > 0:
+		a.add(adviceNumericStrings, e, prefix, e.Bytes-e.NumericBytes)
+	}
+	if e.Type == "string" && e.Expiry <= 0 && e.Encoding != "raw" && e.NumOfElem <= uint64(a.conf.HashMaxListpackValue) {
+		field := bucketField(e.Key, a.c.separators)
+		if field == "" || int64(len(field)) > a.conf.HashMaxListpackValue {
+			return
+		}
+		// an entry of a listpack takes about 2 bytes more than its value
+		cnt := a.add(adviceBucketStrings, e, prefix, 0)
+		cnt.fieldBytes += uint64(len(field)) + 2
+		cnt.valueBytes += e.NumOfElem + 2
+	}
+}
+
+// keyPattern return the longest prefix of the normalized key k up to depth,
+// or k if it has no separator
+func keyPattern(k, sep string, depth int) string {
+	longest := ""
+	for _, prefix := range getPrefixes(k, sep, depth) {
+		if len(prefix) > len(longest) && len(prefix) < len(k) {
+			longest = prefix
+		}
+	}
+	if longest == "" {
+		return k
+	}
+	return longest
+}
+
+// bucketField return the hash field of key if it were bucketed into a hash,
+// the key but its leading segments without digits, empty if it has none
+func bucketField(key, sep string) string {
+	i := strings.IndexAny(key, "0123456789")
+	if i < 0 {
+		i = len(key)
+	}
+	j := strings.LastIndexAny(key[:i], sep)
+	if j < 0 {
+		return ""
+	}
+	return key[j+1:]
+}
+
+// calcu the advice of the counts ranked by savings
+func (a *advisor) calcu() {
+	a.advice = []*Advice{}
+	for key, cnt := range a.counts {
+		adv := &Advice{
+			Kind:    key.Kind,
+			Type:    key.Type,
+			Prefix:  key.Prefix,
+			Num:     cnt.num,
+			Bytes:   cnt.bytes,
+			Savings: cnt.savings,
+		}
+		switch key.Kind {
+		case adviceBucketStrings:
+			if !a.bucket(adv, cnt) {
+				continue
+			}
+		case adviceHashListpack:
+			adv.Advice = raiseAdvice("hash-max-listpack-entries", a.conf.HashMaxListpackEntries, cnt.maxElems,
+				"the hashes are hashtables just above it")
+		case adviceZsetListpack:
+			adv.Advice = raiseAdvice("zset-max-listpack-entries", a.conf.ZsetMaxListpackEntries, cnt.maxElems,
+				"the sorted sets are skiplists just above it")
+		case adviceIntset:
+			adv.Advice = raiseAdvice("set-max-intset-entries", a.conf.SetMaxIntsetEntries, cnt.maxElems,
+				"the sets have integer members only")
+		case adviceNumericStrings:
+			adv.Advice = "store the numbers as integers, scaled if they have decimals, or packed in binary, the values are decimal strings"
+		case adviceLargeNoTTL:
+			adv.Advice = fmt.Sprintf("set a TTL or trim the keys, they are larger than %s and never expire, savings are the bytes of the keys",
+				humanize.Bytes(a.largeBytes))
+		}
+		a.advice = append(a.advice, adv)
+	}
+	a.counts = map[adviceKey]*adviceCount{}
+	sort.Slice(a.advice, func(i, j int) bool {
+		x, y := a.advice[i], a.advice[j]
+		if x.Savings != y.Savings {
+			return x.Savings > y.Savings
+		}
+		if x.Kind != y.Kind {
+			return x.Kind < y.Kind
+		}
+		return x.Prefix < y.Prefix
+	})
+}
+
+// raiseAdvice advise to raise the threshold of directive to maxElems, or to
+// recreate the keys if they fit it already, as keys converted from a compact
+// encoding are only converted back when they are loaded again
+func raiseAdvice(directive string, threshold int64, maxElems uint64, why string) string {
+	if maxElems <= uint64(threshold) {
+		return fmt.Sprintf("recreate the keys or restart redis, they fit %s %d but are not compact", directive, threshold)
+	}
+	return fmt.Sprintf("raise %s from %d to %d, %s", directive, threshold, maxElems, why)
+}
+
+// bucket estimate the savings of the string keys of adv stored as the fields
+// of hashes of hash-max-listpack-entries fields, the Instagram way. The hashes
+// are listpacks with a top level overhead of about the average string key.
+func (a *advisor) bucket(adv *Advice, cnt *adviceCount) bool {
+	perBucket := uint64(a.conf.HashMaxListpackEntries)
+	if cnt.num < a.minBucketKeys || perBucket == 0 {
+		return false
+	}
+	buckets := (cnt.num + perBucket - 1) / perBucket
+	// the listpack header is 7 bytes
+	bucketed := cnt.fieldBytes + cnt.valueBytes + buckets*(cnt.bytes/cnt.num+7)
+	if bucketed >= cnt.bytes {
+		return false
+	}
+	adv.Savings = cnt.bytes - bucketed
+	adv.Advice = fmt.Sprintf("bucket the small strings into %s hashes of %d fields, by the id in the key, the rest of the key as field",
+		humanize.Comma(int64(buckets)), perBucket)
+	return true
+}
+
+// GetAdvice return the memory optimizations ranked by savings, nil unless
+// the counter advises
+func (c *Counter) GetAdvice() []*Advice {
+	if c.advisor == nil {
+		return nil
+	}
+	return c.advisor.advice
+}
+
+// Advise print ranked memory optimizations of rdbfiles to STDOUT
+func Advise(c *cli.Context) error {
+	if c.NArg() < 1 {
+		fmt.Fprintln(c.App.ErrWriter, "advise requires at least 1 argument")
+		cli.ShowCommandHelp(c, "advise")
+		return nil
+	}
+	for _, path := range c.Args() {
+		d := decoder.NewDecoder()
+		if dbs := c.IntSlice("db"); len(dbs) > 0 {
+			d.FilterDB(dbs...)
+		}
+		if err := configureDecoder(c, d); err != nil {
+			return cli.NewExitError(fmt.Sprintf("configure err: %v", err), 1)
+		}
+		cnt, err := newCounter(c)
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("configure err: %v", err), 1)
+		}
+		// the redis config is of the instance, the what-if estimates are
+		// with its thresholds raised
+		conf := d.GetRedisConfig()
+		if conf == nil {
+			conf = decoder.DefaultRedisConfig()
+		}
+		d.SetRedisConfig(adviseConfig(conf))
+		go decodeFile(c, d, path)
+
+		cnt.advisor = newAdvisor(conf, cnt)
+		cnt.CountDecoder(d)
+		printAdvice(c.App.Writer, instanceName(path), cnt.GetAdvice(), c.Int("top"))
+	}
+	return nil
+}
+
+// printAdvice print the top advice of instance as a table
+func printAdvice(out io.Writer, instance string, advice []*Advice, top int) {
+	fmt.Fprintf(out, "# %s\n", instance)
+	if len(advice) == 0 {
+		fmt.Fprintln(out, "no advice")
+		return
+	}
+	if top > 0 && top < len(advice) {
+		advice = advice[:top]
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RANK\tSAVINGS\tBYTES\tKEYS\tTYPE\tPREFIX\tKIND\tADVICE")
+	for i, adv := range advice {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", i+1, humanize.Bytes(adv.Savings), humanize.Bytes(adv.Bytes),
+			humanize.Comma(int64(adv.Num)), adv.Type, adv.Prefix, adv.Kind, adv.Advice)
+	}
+	w.Flush()
+}
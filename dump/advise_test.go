@@ -0,0 +1,66 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xueqiu/rdr/decoder"
+)
+
+func TestAdvise(t *testing.T) {
+	c := NewCounter()
+	c.advisor = newAdvisor(decoder.DefaultRedisConfig(), c)
+	for i := 0; i < 1000; i++ {
+		c.count(&decoder.Entry{Key: "media:" + strconv.Itoa(1000000+i), Bytes: 48, Type: "string", Encoding: "int", NumOfElem: 8, Idle: -1, Freq: -1})
+	}
+	c.count(&decoder.Entry{Key: "user:1:profile", Bytes: 9000, WhatIfBytes: 3000, Type: "hash", Encoding: "hashtable", NumOfElem: 200, Idle: -1, Freq: -1})
+	c.count(&decoder.Entry{Key: "price:1", Bytes: 40, NumericBytes: 24, Type: "string", Encoding: "embstr", NumOfElem: 5, Idle: -1, Freq: -1})
+	c.calcu()
+
+	advice := c.GetAdvice()
+	if assert.Len(t, advice, 3) {
+		// 1000 fields of 9 bytes and values of 10 bytes in 8 hashes of 55 bytes
+		assert.Equal(t, adviceBucketStrings, advice[0].Kind)
+		assert.Equal(t, "media", advice[0].Prefix)
+		assert.Equal(t, uint64(48000-19000-8*55), advice[0].Savings)
+		assert.Equal(t, adviceHashListpack, advice[1].Kind)
+		assert.Equal(t, "user:0", advice[1].Prefix)
+		assert.Equal(t, uint64(6000), advice[1].Savings)
+		assert.Contains(t, advice[1].Advice, "from 128 to 200")
+		assert.Equal(t, adviceNumericStrings, advice[2].Kind)
+	}
+}
+
+func TestAdviseIntset(t *testing.T) {
+	c := NewCounter()
+	c.advisor = newAdvisor(decoder.DefaultRedisConfig(), c)
+	// sets of strings smaller with the raised thresholds, but not intsets
+	for i := 0; i < 10; i++ {
+		c.count(&decoder.Entry{Key: "tags:" + strconv.Itoa(i), Bytes: 9000, WhatIfBytes: 3000, WhatIfEncoding: "listpack", Type: "set", Encoding: "hashtable", NumOfElem: 100, Idle: -1, Freq: -1})
+	}
+	c.count(&decoder.Entry{Key: "ids:1", Bytes: 40000, WhatIfBytes: 8000, WhatIfEncoding: "intset", Type: "set", Encoding: "hashtable", NumOfElem: 1000, Idle: -1, Freq: -1})
+	c.calcu()
+
+	advice := c.GetAdvice()
+	if assert.Len(t, advice, 1) {
+		assert.Equal(t, adviceIntset, advice[0].Kind)
+		assert.Equal(t, "ids", advice[0].Prefix)
+		assert.Equal(t, uint64(32000), advice[0].Savings)
+		assert.Contains(t, advice[0].Advice, "from 512 to 1000")
+	}
+}
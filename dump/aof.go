@@ -0,0 +1,271 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dongmx/rdb"
+	"github.com/xueqiu/rdr/decoder"
+)
+
+// aofSource is the files of an append only file. An append only file of
+// redis 7 is a directory with a manifest listing a base file and incr files,
+// the base is a rdbfile or RESP commands, the incr files are RESP commands.
+// Before redis 7 it is a single file with an optional rdb preamble.
+type aofSource struct {
+	base  string
+	incrs []string
+	// offset of the commands of the base file, after its rdb preamble
+	tail int64
+}
+
+// manifestFile is a file listed in the manifest of a redis 7 append only file
+type manifestFile struct {
+	name string
+	seq  int
+	typ  string
+}
+
+// findAOF return the append only file at path, nil if path is a rdbfile
+func findAOF(path string) (*aofSource, error) {
+	if path == StdinPath {
+		return nil, nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		manifests, err := filepath.Glob(filepath.Join(path, "*.manifest"))
+		if err != nil || len(manifests) == 0 {
+			return nil, fmt.Errorf("no manifest of append only file in %s", path)
+		}
+		return readManifest(manifests[0])
+	}
+	if strings.HasSuffix(path, ".manifest") {
+		return readManifest(path)
+	}
+
+	f, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	magic := make([]byte, 1)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return nil, nil
+	}
+	// RESP commands only, or a rdb preamble
+	if magic[0] == '*' || strings.Contains(filepath.Base(path), ".aof") {
+		return &aofSource{base: path}, nil
+	}
+	return nil, nil
+}
+
+// readManifest read the base and incr files of a manifest of redis 7
+func readManifest(path string) (*aofSource, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(path)
+	src := &aofSource{}
+	incrs := []manifestFile{}
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields, err := splitManifestLine(line)
+		if err != nil || len(fields)%2 != 0 {
+			return nil, fmt.Errorf("invalid manifest %s at line %d: %q", path, i+1, line)
+		}
+		f := manifestFile{}
+		for j := 0; j < len(fields); j += 2 {
+			switch fields[j] {
+			case "file":
+				f.name = fields[j+1]
+			case "seq":
+				f.seq, _ = strconv.Atoi(fields[j+1])
+			case "type":
+				f.typ = fields[j+1]
+			}
+		}
+		switch f.typ {
+		case "b":
+			src.base = filepath.Join(dir, f.name)
+		case "i":
+			incrs = append(incrs, f)
+		}
+	}
+	sort.Slice(incrs, func(i, j int) bool { return incrs[i].seq < incrs[j].seq })
+	for _, f := range incrs {
+		src.incrs = append(src.incrs, filepath.Join(dir, f.name))
+	}
+	return src, nil
+}
+
+// splitManifestLine split a line of manifest into fields, a field with
+// spaces is quoted
+func splitManifestLine(line string) ([]string, error) {
+	fields := []string{}
+	for line = strings.TrimLeft(line, " "); line != ""; line = strings.TrimLeft(line, " ") {
+		if line[0] != '"' {
+			end := strings.IndexByte(line, ' ')
+			if end < 0 {
+				end = len(line)
+			}
+			fields = append(fields, line[:end])
+			line = line[end:]
+			continue
+		}
+		end := 1
+		for end < len(line) && line[end] != '"' {
+			if line[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(line) {
+			return nil, fmt.Errorf("unterminated quote")
+		}
+		field, err := strconv.Unquote(line[:end+1])
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+		line = line[end+1:]
+	}
+	return fields, nil
+}
+
+// decodeAOF decode the rdb part of an append only file to d, with the keys
+// adjusted by replaying the commands after it. The checksum of the rdb part
+// is checked if verify. In tolerant mode a corrupt rdb preamble is decoded
+// without replaying the commands, as they can not be found after it.
+// Truncation of the files is reported to w.
+func decodeAOF(src *aofSource, d *decoder.Decoder, verify, tolerant bool, w io.Writer) error {
+	aof := decoder.NewAOF(src.commandFiles)
+	if src.base != "" {
+		var err error
+		if src.tail, err = readAOFCommands(aof, src.base, -1, w); err != nil {
+			if _, ok := err.(*rdb.DecodeError); !ok || !tolerant {
+				return err
+			}
+			d.AddError(fmt.Errorf("commands of the append only file are not replayed: %v", err))
+			return decodeAOFBase(src.base, d, decodeOptions(d, verify, tolerant))
+		}
+	}
+	for _, incr := range src.incrs {
+		if _, err := readAOFCommands(aof, incr, 0, w); err != nil {
+			return err
+		}
+	}
+
+	if src.tail == 0 {
+		aof.Replay(d)
+		return nil
+	}
+	ad := aof.Decoder(d)
+	return decodeAOFBase(src.base, ad, decodeOptions(ad.(errorAdder), verify, tolerant))
+}
+
+// decodeAOFBase decode the rdb preamble of the base file of an append only file
+func decodeAOFBase(path string, d rdb.Decoder, opts rdb.Options) error {
+	f, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, _, err = rdb.DecodeWithOptions(f, d, opts)
+	return err
+}
+
+// readAOFCommands read the commands of an append only file from offset, see
+// readAOFFile. A truncated command at the end is ignored as redis does with
+// aof-load-truncated.
+func readAOFCommands(aof *decoder.AOF, path string, offset int64, w io.Writer) (int64, error) {
+	offset, err := readAOFFile(path, offset, aof.ReadCommands)
+	if err == io.ErrUnexpectedEOF {
+		fmt.Fprintf(w, "append only file %s is truncated, the last command is ignored\n", path)
+		err = nil
+	}
+	return offset, err
+}
+
+// readAOFFile call read with the commands of the file at path, which start
+// at offset. If offset is negative they start after the rdb preamble if the
+// file has one, which is parsed to find its end. The offset of the commands
+// is returned.
+func readAOFFile(path string, offset int64, read func(r *bufio.Reader) error) (int64, error) {
+	f, err := Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	cr := &countingReader{r: f}
+	r := bufio.NewReader(cr)
+	if offset < 0 {
+		offset = 0
+		if magic, _ := r.Peek(5); bytes.Equal(magic, []byte("REDIS")) {
+			if err := decoder.SkipRDB(r); err != nil {
+				return 0, err
+			}
+			offset = cr.n - int64(r.Buffered())
+		}
+	} else if _, err := io.CopyN(ioutil.Discard, r, offset); err != nil {
+		return 0, err
+	}
+	return offset, read(r)
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// commandFiles call read with the base and the incr files in order, the rdb
+// preamble of the base file is skipped to the offset of its commands found
+// when they were read first
+func (src *aofSource) commandFiles(read func(r *bufio.Reader) error) error {
+	if src.base != "" {
+		if _, err := readAOFFile(src.base, src.tail, read); err != nil {
+			return err
+		}
+	}
+	for _, incr := range src.incrs {
+		if _, err := readAOFFile(incr, 0, read); err != nil {
+			return err
+		}
+	}
+	return nil
+}
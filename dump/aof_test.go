@@ -0,0 +1,67 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xueqiu/rdr/decoder"
+)
+
+func TestAOFManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "appendonlydir")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	// a rdb of version 9 with the strings a and b, without checksum
+	preamble := "REDIS0009\xfe\x00\x00\x01a\x011\x00\x01b\x012\xff\x00\x00\x00\x00\x00\x00\x00\x00"
+	files := map[string]string{
+		"appendonly.aof.manifest":   "file appendonly.aof.1.base.aof seq 1 type b\nfile appendonly.aof.2.incr.aof seq 2 type i\nfile appendonly.aof.1.incr.aof seq 1 type i\n",
+		"appendonly.aof.1.base.aof": preamble + "*3\r\n$3\r\nset\r\n$1\r\nf\r\n$1\r\n6\r\n",
+		"appendonly.aof.1.incr.aof": "*3\r\n$3\r\nset\r\n$1\r\nc\r\n$1\r\n3\r\n*2\r\n$3\r\ndel\r\n$1\r\na\r\n",
+		"appendonly.aof.2.incr.aof": "*3\r\n$6\r\nrename\r\n$1\r\nc\r\n$1\r\nd\r\n*3\r\n$3\r\nset\r\n$1\r\ne\r\n$1\r\n5\r\n",
+	}
+	for name, content := range files {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)) {
+			return
+		}
+	}
+
+	src, err := findAOF(dir)
+	if !assert.NoError(t, err) || !assert.NotNil(t, src) {
+		return
+	}
+	assert.Equal(t, filepath.Join(dir, "appendonly.aof.1.base.aof"), src.base)
+	assert.Equal(t, []string{filepath.Join(dir, "appendonly.aof.1.incr.aof"), filepath.Join(dir, "appendonly.aof.2.incr.aof")}, src.incrs)
+
+	d := decoder.NewDecoder()
+	w := &bytes.Buffer{}
+	assert.NoError(t, decodeAOF(src, d, false, false, w))
+	// the commands are replayed from the end of the rdb preamble found first
+	assert.Equal(t, int64(len(preamble)), src.tail)
+	keys := []string{}
+	for e := range d.Entries {
+		keys = append(keys, e.Key)
+	}
+	assert.Equal(t, []string{"b", "d", "e", "f"}, keys)
+	assert.Empty(t, d.GetErrors())
+	assert.Empty(t, w.String())
+}
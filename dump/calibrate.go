@@ -0,0 +1,182 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+	"github.com/xueqiu/rdr/decoder"
+)
+
+// Calibration compares the estimated memory use with the used-mem of the
+// rdb file, the memory redis reported when the snapshot was taken
+type Calibration struct {
+	UsedMem int64
+	// EstimatedBytes is the estimate of the keys, before scaling
+	EstimatedBytes   uint64
+	MainDictBytes    uint64
+	ExpiresDictBytes uint64
+	// UnexplainedBytes is used-mem minus the estimates, the replication
+	// backlog, client buffers, lua scripts, memory of redis at startup and
+	// errors of the memory model
+	UnexplainedBytes int64
+	// Ratio is the estimates to used-mem
+	Ratio float64
+	// Scale is the factor the bytes of keys are multiplied by, 1 unless
+	// calibrated
+	Scale float64
+}
+
+// GetCalibration return the calibration against used-mem, nil if the rdb
+// file has no used-mem
+func (c *Counter) GetCalibration() *Calibration {
+	if c.usedMem <= 0 {
+		return nil
+	}
+	cal := &Calibration{
+		UsedMem:        c.usedMem,
+		EstimatedBytes: c.estimatedBytes,
+		Scale:          1,
+	}
+	for _, ks := range c.keyspaces {
+		cal.MainDictBytes += ks.MainDictBytes
+		cal.ExpiresDictBytes += ks.ExpiresDictBytes
+	}
+	estimated := cal.EstimatedBytes + cal.MainDictBytes + cal.ExpiresDictBytes
+	cal.UnexplainedBytes = c.usedMem - int64(estimated)
+	cal.Ratio = float64(estimated) / float64(c.usedMem)
+	if c.scale > 0 {
+		cal.Scale = c.scale
+	}
+	return cal
+}
+
+// calibrate scale the estimates of keys counted by cnt so that they add up
+// to the used-mem of the rdbfile with the keyspace overhead if the calibrate
+// flag is set
+func calibrate(c *cli.Context, cnt *Counter) {
+	if !c.Bool("calibrate") {
+		return
+	}
+	if len(c.IntSlice("db")) > 0 {
+		fmt.Fprintln(c.App.ErrWriter, "calibrate err: used-mem is of all databases, the estimates are not scaled with --db")
+		return
+	}
+	cnt.calibrate = true
+}
+
+// calcuScale find the scale of the estimates once all keys are counted, the
+// estimates are scaled in calcu as the scale is linear
+func (c *Counter) calcuScale() {
+	usedMem := c.usedMem
+	for _, ks := range c.keyspaces {
+		usedMem -= int64(ks.MainDictBytes + ks.ExpiresDictBytes)
+	}
+	if usedMem <= 0 || c.estimatedBytes == 0 {
+		c.errors = append(c.errors, &decoder.ErrorEntry{Offset: -1,
+			Error: "calibrate: the rdbfile has no used-mem or no keys, the estimates are not scaled"})
+		return
+	}
+	c.scale = float64(usedMem) / float64(c.estimatedBytes)
+	// the databases share the entries
+	c.scaledEntries = map[*decoder.Entry]bool{}
+	for _, dbc := range c.dbCounters {
+		dbc.scale, dbc.scaledEntries = c.scale, c.scaledEntries
+	}
+}
+
+// scaleBytes multiply the bytes counted by scale, after the prefixes of a
+// sketch or spilled runs are collected
+func (c *Counter) scaleBytes() {
+	for _, e := range *c.largestEntries {
+		c.scaleEntry(e)
+	}
+	for _, e := range *c.hottestEntries {
+		c.scaleEntry(e)
+	}
+	for _, p := range *c.largestKeyPrefixes {
+		p.Bytes = c.scaled(p.Bytes)
+		p.BytesError = c.scaled(p.BytesError)
+	}
+	for _, m := range []map[typeKey]uint64{c.lengthLevelBytes, c.keyPrefixBytes, c.keyPrefixWhatIf} {
+		for k, v := range m {
+			m[k] = c.scaled(v)
+		}
+	}
+	for _, m := range []map[string]uint64{c.typeBytes, c.coldBytes, c.noTTLBytes, c.expiredBytes} {
+		for k, v := range m {
+			m[k] = c.scaled(v)
+		}
+	}
+	for _, m := range []map[encodingKey]uint64{c.encodingBytes, c.keyPrefixEncBytes} {
+		for k, v := range m {
+			m[k] = c.scaled(v)
+		}
+	}
+	for _, m := range []map[levelKey]uint64{c.keyPrefixIdleBytes, c.keyPrefixTTLBytes} {
+		for k, v := range m {
+			m[k] = c.scaled(v)
+		}
+	}
+	for k, v := range c.slotBytes {
+		c.slotBytes[k] = c.scaled(v)
+	}
+	c.whatIfBytes = c.scaled(c.whatIfBytes)
+	for _, buckets := range []map[int64]*expireBucket{c.expires.seconds, c.expires.minutes} {
+		for _, b := range buckets {
+			b.Bytes = c.scaled(b.Bytes)
+			for _, p := range b.prefixes {
+				p.Bytes = c.scaled(p.Bytes)
+			}
+		}
+	}
+	if c.advisor != nil {
+		for _, cnt := range c.advisor.counts {
+			cnt.bytes = c.scaled(cnt.bytes)
+			cnt.savings = c.scaled(cnt.savings)
+		}
+	}
+	if c.groups != nil {
+		for _, g := range c.groups.groups {
+			c.scaleGroup(g)
+		}
+		for _, g := range c.groups.teams {
+			c.scaleGroup(g)
+		}
+	}
+}
+
+func (c *Counter) scaled(v uint64) uint64 {
+	return uint64(float64(v)*c.scale + 0.5)
+}
+
+// scaleEntry scale e unless it is already, as it may be among the largest
+// keys of several statistics
+func (c *Counter) scaleEntry(e *decoder.Entry) {
+	if c.scaledEntries[e] {
+		return
+	}
+	c.scaledEntries[e] = true
+	e.Bytes = c.scaled(e.Bytes)
+	e.WhatIfBytes = c.scaled(e.WhatIfBytes)
+}
+
+func (c *Counter) scaleGroup(g *groupCounter) {
+	g.entry.Bytes = c.scaled(g.entry.Bytes)
+	for _, e := range *g.largest {
+		c.scaleEntry(e)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/dongmx/rdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/xueqiu/rdr/decoder"
+)
+
+// countCalibrated count a rdb of version 9 with used-mem and n strings
+func countCalibrated(calibrate bool, usedMem string, n int) *Counter {
+	b := []byte("REDIS0009\xfa\x08used-mem")
+	b = append(b, byte(len(usedMem)))
+	b = append(b, usedMem...)
+	b = append(b, 0xfe, 0)
+	for i := 0; i < n; i++ {
+		b = append(b, rdbString("user:"+strconv.Itoa(i), "value:"+strconv.Itoa(i))...)
+	}
+	b = append(b, 0xff)
+	b = append(b, make([]byte, 8)...)
+
+	d := decoder.NewDecoder()
+	go rdb.Decode(bytes.NewReader(b), d)
+	cnt := NewCounter()
+	cnt.calibrate = calibrate
+	cnt.CountDecoder(d)
+	return cnt
+}
+
+func TestCalibrate(t *testing.T) {
+	raw := countCalibrated(false, "100000", 20)
+	cal := raw.GetCalibration()
+	if !assert.NotNil(t, cal) {
+		return
+	}
+	assert.Equal(t, 1.0, cal.Scale)
+	assert.Equal(t, cal.EstimatedBytes, raw.typeBytes["string"])
+
+	cnt := countCalibrated(true, "100000", 20)
+	cal = cnt.GetCalibration()
+	scale := float64(100000-cal.MainDictBytes-cal.ExpiresDictBytes) / float64(cal.EstimatedBytes)
+	assert.InDelta(t, scale, cal.Scale, 1e-9)
+	// the totals add up to used-mem with the keyspace overhead
+	assert.InDelta(t, 100000-cal.MainDictBytes-cal.ExpiresDictBytes, cnt.typeBytes["string"], 1)
+	largest := cnt.GetLargestEntries(1)[0]
+	assert.Equal(t, uint64(float64(raw.GetLargestEntries(1)[0].Bytes)*scale+0.5), largest.Bytes)
+	prefixes := cnt.GetLargestKeyPrefixes()
+	if assert.Len(t, prefixes, 1) {
+		assert.InDelta(t, cnt.typeBytes["string"], prefixes[0].Bytes, 1)
+	}
+
+	// used-mem below the keyspace overhead is not scaled to
+	cnt = countCalibrated(true, "10", 20)
+	assert.Equal(t, 1.0, cnt.GetCalibration().Scale)
+	assert.Equal(t, raw.typeBytes["string"], cnt.typeBytes["string"])
+	assert.Len(t, cnt.GetErrors(), 1)
+}
@@ -29,9 +29,12 @@ func NewCounter() *Counter {
 	heap.Init(h)
 	p := &prefixHeap{}
 	heap.Init(p)
+	f := &freqHeap{}
+	heap.Init(f)
 	return &Counter{
 		largestEntries:     h,
 		largestKeyPrefixes: p,
+		hottestEntries:     f,
 		lengthLevel0:       100,
 		lengthLevel1:       1000,
 		lengthLevel2:       10000,
@@ -43,12 +46,63 @@ func NewCounter() *Counter {
 		keyPrefixNum:       map[typeKey]uint64{},
 		typeBytes:          map[string]uint64{},
 		typeNum:            map[string]uint64{},
+		encodingBytes:      map[encodingKey]uint64{},
+		encodingNum:        map[encodingKey]uint64{},
+		keyPrefixEncBytes:  map[encodingKey]uint64{},
+		keyPrefixEncNum:    map[encodingKey]uint64{},
 		separators:         ":;,_- ",
+		normalizers:        builtinNormalizers,
 		slotBytes:          map[int]uint64{},
 		slotNum:            map[int]uint64{},
+		idleLevels:         defaultIdleLevels,
+		keyPrefixIdleBytes: map[levelKey]uint64{},
+		keyPrefixIdleNum:   map[levelKey]uint64{},
+		coldIdle:           30 * 24 * 3600,
+		coldBytes:          map[string]uint64{},
+		coldNum:            map[string]uint64{},
+		ttlLevels:          defaultTTLLevels,
+		keyPrefixTTLBytes:  map[levelKey]uint64{},
+		keyPrefixTTLNum:    map[levelKey]uint64{},
+		noTTLBytes:         map[string]uint64{},
+		noTTLNum:           map[string]uint64{},
+		expiredBytes:       map[string]uint64{},
+		expiredNum:         map[string]uint64{},
+		expires:            newExpireCounter(),
+		dbCounters:         map[int]*Counter{},
 	}
 }
 
+// level is a lower bound of LRU idle time or TTL
+type level struct {
+	Name    string
+	Seconds int64
+}
+
+// must be in ascending order
+var defaultIdleLevels = []level{
+	{"0s", 0},
+	{"1h", 3600},
+	{"1d", 24 * 3600},
+	{"7d", 7 * 24 * 3600},
+	{"30d", 30 * 24 * 3600},
+}
+
+// must be in ascending order
+var defaultTTLLevels = []level{
+	{"0s", 0},
+	{"1m", 60},
+	{"1h", 3600},
+	{"1d", 24 * 3600},
+	{"7d", 7 * 24 * 3600},
+	{"30d", 30 * 24 * 3600},
+}
+
+// TTL levels of keys expired at snapshot time and keys without TTL
+const (
+	ttlLevelExpired = "expired"
+	ttlLevelNone    = "none"
+)
+
 // Counter for redis memory useage
 type Counter struct {
 	largestEntries     *entryHeap
@@ -63,10 +117,79 @@ type Counter struct {
 	keyPrefixBytes     map[typeKey]uint64
 	keyPrefixNum       map[typeKey]uint64
 	separators         string
-	typeBytes          map[string]uint64
-	typeNum            map[string]uint64
+	// keys have maxPrefixDepth prefixes at most, all if 0
+	maxPrefixDepth int
+	// identifiers of keys are replaced by the normalizers before the
+	// numbers are reset
+	normalizers []*KeyNormalizer
+	typeBytes   map[string]uint64
+	typeNum     map[string]uint64
+	// by type and encoding, of all keys and of each key prefix
+	encodingBytes      map[encodingKey]uint64
+	encodingNum        map[encodingKey]uint64
+	keyPrefixEncBytes  map[encodingKey]uint64
+	keyPrefixEncNum    map[encodingKey]uint64
+	encodingEntries    []*EncodingEntry
+	prefixEncEntries   []*EncodingEntry
 	slotBytes          map[int]uint64
 	slotNum            map[int]uint64
+	hottestEntries     *freqHeap
+	idleLevels         []level
+	keyPrefixIdleBytes map[levelKey]uint64
+	keyPrefixIdleNum   map[levelKey]uint64
+	idleLevelEntries   []*LevelEntry
+	// keys idle longer than coldIdle seconds are cold
+	coldIdle  int64
+	coldBytes map[string]uint64
+	coldNum   map[string]uint64
+	// TTLs are computed against ctime, the unix time of the snapshot
+	ctime             int64
+	ttlLevels         []level
+	keyPrefixTTLBytes map[levelKey]uint64
+	keyPrefixTTLNum   map[levelKey]uint64
+	ttlLevelEntries   []*LevelEntry
+	ttlLeaks          []*TTLLeakEntry
+	noTTLBytes        map[string]uint64
+	noTTLNum          map[string]uint64
+	expiredBytes      map[string]uint64
+	expiredNum        map[string]uint64
+	expires           *expireCounter
+	// errors of the decoding, the keys with an error are not counted
+	errors []*decoder.ErrorEntry
+	// aux fields of the rdb file
+	metadata []*decoder.AuxField
+	// name of the memory model the memory use is estimated with
+	memoryModel string
+	// used-mem of the rdb file and the keyspaces of the databases
+	usedMem   int64
+	keyspaces []*decoder.Keyspace
+	// the bytes counted are multiplied by scale in calcu if calibrate, the
+	// Bytes of entries add up to estimatedBytes before
+	calibrate      bool
+	scale          float64
+	scaledEntries  map[*decoder.Entry]bool
+	estimatedBytes uint64
+	// WhatIfBytes of entries by key prefix, nil without what-if estimates
+	keyPrefixWhatIf map[typeKey]uint64
+	whatIfBytes     uint64
+	whatIfEntries   []*WhatIfEntry
+	// statistics of each database, nil in a database's own counter
+	dbCounters map[int]*Counter
+	// perDB keeps all statistics of each database, only the totals by type
+	// otherwise, which a database's own counter is totalsOnly of
+	perDB      bool
+	totalsOnly bool
+	// advisor of memory optimizations, nil unless advised
+	advisor *advisor
+	// keys by group and team of the key rules, nil without rules
+	groups *groupCounters
+	// templates the keys are grouped by instead of prefixes, nil to group
+	// by prefixes
+	patterns *patternMiner
+	// the sketch or the spilled runs the key prefixes are counted in, both
+	// nil to count them in memory
+	sketch *prefixSketch
+	spill  *prefixSpill
 }
 
 // Count by various dimensions
@@ -74,8 +197,111 @@ func (c *Counter) Count(in <-chan *decoder.Entry) {
 	for e := range in {
 		c.count(e)
 	}
+	c.calcu()
+}
+
+// CountDecoder count entries of d by various dimensions, TTLs are computed
+// against the ctime of the rdb file
+func (c *Counter) CountDecoder(d *decoder.Decoder) {
+	for e := range d.Entries {
+		// aux fields and the configuration of d come before any key
+		if c.ctime == 0 {
+			c.ctime = d.GetTimestamp()
+		}
+		if c.keyPrefixWhatIf == nil && d.GetRedisConfig() != nil {
+			c.keyPrefixWhatIf = map[typeKey]uint64{}
+		}
+		c.estimatedBytes += e.Bytes
+		c.count(e)
+	}
+	c.errors = d.GetErrors()
+	c.metadata = d.GetAux()
+	c.memoryModel, _ = d.GetMemoryModel()
+	c.usedMem = d.GetUsedMem()
+	c.keyspaces = d.GetKeyspaces()
+	for _, ks := range c.keyspaces {
+		if dbc := c.dbCounters[ks.DB]; dbc != nil {
+			dbc.keyspaces = []*decoder.Keyspace{ks}
+		}
+	}
+	if c.calibrate {
+		c.calcuScale()
+	}
+	c.calcu()
+}
+
+// calcu the final statistics after all entries are counted
+func (c *Counter) calcu() {
+	if c.patterns != nil {
+		c.resolvePatterns()
+	}
 	// get largest prefixes
+	switch {
+	case c.sketch != nil:
+		c.calcuSketch(1000)
+	case c.spill != nil:
+		c.calcuSpill(1000)
+	}
+	if c.scale > 0 {
+		c.scaleBytes()
+	}
 	c.calcuLargestKeyPrefix(1000)
+	c.calcuWhatIf()
+	c.calcuIdleLevel()
+	c.calcuTTLLevel()
+	c.calcuEncoding()
+	c.expires.calcu(c.ctime)
+	if c.advisor != nil {
+		c.advisor.calcu()
+	}
+	for _, dbc := range c.dbCounters {
+		dbc.calcu()
+	}
+}
+
+// SetKeyPrefix set the characters keys are split into prefixes at, but those
+// of segmentChars which are part of the segments between prefixes, and the
+// maximum number of prefixes of a key, all if 0
+func (c *Counter) SetKeyPrefix(separators, segmentChars string, maxDepth int) {
+	c.separators = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(segmentChars, r) {
+			return -1
+		}
+		return r
+	}, separators)
+	c.maxPrefixDepth = maxDepth
+}
+
+// SetKeyNormalizers set the normalizers of the identifiers of keys, the
+// built-in ones by default
+func (c *Counter) SetKeyNormalizers(normalizers []*KeyNormalizer) {
+	c.normalizers = normalizers
+}
+
+// GetDBs return the sorted database numbers
+func (c *Counter) GetDBs() []int {
+	dbs := []int{}
+	for db := range c.dbCounters {
+		dbs = append(dbs, db)
+	}
+	sort.Ints(dbs)
+	return dbs
+}
+
+// GetDBCounter return the statistics of a database, nil if it has no keys
+func (c *Counter) GetDBCounter(db int) *Counter {
+	return c.dbCounters[db]
+}
+
+// SetPerDB keep all statistics of each database rather than the totals by
+// type only, which takes about as much memory again
+func (c *Counter) SetPerDB(perDB bool) {
+	c.perDB = perDB
+}
+
+// TotalsOnly reports whether c has the totals by type of a database only
+func (c *Counter) TotalsOnly() bool {
+	return c.totalsOnly
 }
 
 // GetLargestEntries from heap, num max is 500
@@ -107,6 +333,133 @@ func (c *Counter) GetLargestKeyPrefixes() []*PrefixEntry {
 	return res
 }
 
+// GetHottestEntries from heap, the keys with the highest LFU counter
+func (c *Counter) GetHottestEntries(num int) []*decoder.Entry {
+	res := []*decoder.Entry{}
+
+	// get a copy of c.hottestEntries
+	for i := 0; i < c.hottestEntries.Len(); i++ {
+		entries := *c.hottestEntries
+		res = append(res, entries[i])
+	}
+	sort.Sort(sort.Reverse(freqHeap(res)))
+	if num < len(res) {
+		res = res[:num]
+	}
+	return res
+}
+
+// GetIdleLevelCount return the LRU idle time histograms of the largest key prefixes
+func (c *Counter) GetIdleLevelCount() map[string][]*LevelEntry {
+	return groupByPrefix(c.idleLevelEntries)
+}
+
+// GetTTLLevelCount return the TTL histograms of the largest key prefixes
+func (c *Counter) GetTTLLevelCount() map[string][]*LevelEntry {
+	return groupByPrefix(c.ttlLevelEntries)
+}
+
+// GetTTLLeaks return the largest key prefixes that most keys have a TTL
+// but a minority never expire
+func (c *Counter) GetTTLLeaks() []*TTLLeakEntry {
+	return c.ttlLeaks
+}
+
+// GetEncodingCount return the number and bytes of keys by type and encoding
+func (c *Counter) GetEncodingCount() []*EncodingEntry {
+	return c.encodingEntries
+}
+
+// GetKeyPrefixEncodingCount return the number and bytes of keys by type and
+// encoding of the largest key prefixes
+func (c *Counter) GetKeyPrefixEncodingCount() map[string][]*EncodingEntry {
+	res := map[string][]*EncodingEntry{}
+	for _, entry := range c.prefixEncEntries {
+		res[entry.Prefix] = append(res[entry.Prefix], entry)
+	}
+	return res
+}
+
+func groupByPrefix(entries []*LevelEntry) map[string][]*LevelEntry {
+	res := map[string][]*LevelEntry{}
+	for _, entry := range entries {
+		res[entry.Prefix] = append(res[entry.Prefix], entry)
+	}
+	return res
+}
+
+// GetColdBytes return memory held by keys idle longer than the cold threshold by type
+func (c *Counter) GetColdBytes() map[string]uint64 {
+	return c.coldBytes
+}
+
+// GetColdNum return number of keys idle longer than the cold threshold by type
+func (c *Counter) GetColdNum() map[string]uint64 {
+	return c.coldNum
+}
+
+// GetNoTTLBytes return memory held by keys without TTL by type
+func (c *Counter) GetNoTTLBytes() map[string]uint64 {
+	return c.noTTLBytes
+}
+
+// GetNoTTLNum return number of keys without TTL by type
+func (c *Counter) GetNoTTLNum() map[string]uint64 {
+	return c.noTTLNum
+}
+
+// GetExpiredBytes return memory held by keys already expired at snapshot time by type
+func (c *Counter) GetExpiredBytes() map[string]uint64 {
+	return c.expiredBytes
+}
+
+// GetExpiredNum return number of keys already expired at snapshot time by type
+func (c *Counter) GetExpiredNum() map[string]uint64 {
+	return c.expiredNum
+}
+
+// GetExpireSpikes return the seconds and minutes that much more keys expire
+// in than the average
+func (c *Counter) GetExpireSpikes() []*ExpireSpike {
+	return c.expires.spikes
+}
+
+// GetExpireTimeline return number of keys expiring in each minute after the snapshot
+func (c *Counter) GetExpireTimeline() []*ExpireBucket {
+	return c.expires.timeline
+}
+
+// GetMetadata return the aux fields of the rdb file
+func (c *Counter) GetMetadata() []*decoder.AuxField {
+	if c.metadata == nil {
+		return []*decoder.AuxField{}
+	}
+	return c.metadata
+}
+
+// GetKeyspaceOverhead return the memory use of the main dicts and the
+// expires dicts of the databases, which is not in the bytes of keys
+func (c *Counter) GetKeyspaceOverhead() uint64 {
+	overhead := uint64(0)
+	for _, ks := range c.keyspaces {
+		overhead += ks.MainDictBytes + ks.ExpiresDictBytes
+	}
+	return overhead
+}
+
+// GetMemoryModel return the name of the memory model of the estimates
+func (c *Counter) GetMemoryModel() string {
+	return c.memoryModel
+}
+
+// GetErrors return the errors of the decoding
+func (c *Counter) GetErrors() []*decoder.ErrorEntry {
+	if c.errors == nil {
+		return []*decoder.ErrorEntry{}
+	}
+	return c.errors
+}
+
 // GetLenLevelCount from map
 func (c *Counter) GetLenLevelCount() []*PrefixEntry {
 	res := []*PrefixEntry{}
@@ -124,11 +477,82 @@ func (c *Counter) GetLenLevelCount() []*PrefixEntry {
 }
 
 func (c *Counter) count(e *decoder.Entry) {
+	if c.totalsOnly {
+		c.countByType(e)
+		return
+	}
+	c.countKey(e, c.keyPrefixes(e))
+}
+
+// countKey count e with its prefixes, which are computed once for c and the
+// counter of its database
+func (c *Counter) countKey(e *decoder.Entry, prefixes []string) {
+	if c.totalsOnly {
+		c.countByType(e)
+		return
+	}
 	c.countLargestEntries(e, 500)
+	c.countHottestEntries(e, 500)
 	c.countByType(e)
+	c.countByIdle(e)
+	c.countByTTL(e)
 	c.countByLength(e)
-	c.countByKeyPrefix(e)
+	c.countByKeyPrefix(e, prefixes)
 	c.countBySlot(e)
+	c.countByDB(e, prefixes)
+	c.whatIfBytes += e.WhatIfBytes
+	if c.advisor != nil {
+		c.advisor.count(e)
+	}
+	if c.groups != nil {
+		c.groups.count(e)
+	}
+}
+
+func (c *Counter) countByDB(e *decoder.Entry, prefixes []string) {
+	if c.dbCounters == nil {
+		return
+	}
+	dbc, ok := c.dbCounters[e.DB]
+	if !ok {
+		dbc = c.newDBCounter(e.DB)
+		c.dbCounters[e.DB] = dbc
+	}
+	dbc.countKey(e, prefixes)
+}
+
+// newDBCounter return a Counter for database db with the same options as c
+func (c *Counter) newDBCounter(db int) *Counter {
+	dbc := NewCounter()
+	dbc.separators = c.separators
+	dbc.maxPrefixDepth = c.maxPrefixDepth
+	dbc.normalizers = c.normalizers
+	dbc.idleLevels = c.idleLevels
+	dbc.coldIdle = c.coldIdle
+	dbc.ctime = c.ctime
+	dbc.ttlLevels = c.ttlLevels
+	dbc.expires.expireWindow = c.expires.expireWindow
+	dbc.expires.spikeRatio = c.expires.spikeRatio
+	dbc.dbCounters = nil
+	dbc.totalsOnly = !c.perDB
+	if dbc.totalsOnly {
+		return dbc
+	}
+	if c.keyPrefixWhatIf != nil {
+		dbc.keyPrefixWhatIf = map[typeKey]uint64{}
+	}
+	if c.groups != nil {
+		dbc.groups = newGroupCounters(c.groups.rules)
+	}
+	// the prefixes of the keys are the labels of the templates of c
+	dbc.patterns = c.patterns
+	if c.sketch != nil {
+		dbc.sketch = c.sketch.database(db)
+	}
+	if c.spill != nil {
+		dbc.spill = &prefixSpill{dir: c.spill.dir, maxPrefixes: c.spill.maxPrefixes}
+	}
+	return dbc
 }
 
 func (c *Counter) countLargestEntries(e *decoder.Entry, num int) {
@@ -139,6 +563,17 @@ func (c *Counter) countLargestEntries(e *decoder.Entry, num int) {
 	}
 }
 
+func (c *Counter) countHottestEntries(e *decoder.Entry, num int) {
+	if e.Freq < 0 {
+		return
+	}
+	heap.Push(c.hottestEntries, e)
+	l := c.hottestEntries.Len()
+	if l > num {
+		heap.Pop(c.hottestEntries)
+	}
+}
+
 func (c *Counter) countByLength(e *decoder.Entry) {
 	key := typeKey{
 		Type: e.Type,
@@ -172,28 +607,137 @@ func (c *Counter) countByLength(e *decoder.Entry) {
 func (c *Counter) countByType(e *decoder.Entry) {
 	c.typeNum[e.Type]++
 	c.typeBytes[e.Type] += e.Bytes
+	enc := encodingKey{Type: e.Type, Encoding: e.Encoding}
+	c.encodingNum[enc]++
+	c.encodingBytes[enc] += e.Bytes
 }
 
-func (c *Counter) countByKeyPrefix(e *decoder.Entry) {
-	// reset all numbers to 0
-	k := strings.Map(func(c rune) rune {
-		if c >= 48 && c <= 57 { //48 == "0" 57 == "9"
-			return '0'
+func (c *Counter) countByIdle(e *decoder.Entry) {
+	if e.Idle < 0 {
+		return
+	}
+	if e.Idle > c.coldIdle {
+		c.coldNum[e.Type]++
+		c.coldBytes[e.Type] += e.Bytes
+	}
+}
+
+func (c *Counter) countByTTL(e *decoder.Entry) {
+	switch c.ttlLevelOf(e) {
+	case ttlLevelNone:
+		c.noTTLNum[e.Type]++
+		c.noTTLBytes[e.Type] += e.Bytes
+	case ttlLevelExpired:
+		c.expiredNum[e.Type]++
+		c.expiredBytes[e.Type] += e.Bytes
+	}
+}
+
+// ttlLevelOf return the TTL level of e at snapshot time
+func (c *Counter) ttlLevelOf(e *decoder.Entry) string {
+	if e.Expiry <= 0 {
+		return ttlLevelNone
+	}
+	ttl := e.Expiry - c.ctime*1000
+	if ttl <= 0 {
+		return ttlLevelExpired
+	}
+	return levelOf(c.ttlLevels, ttl/1000)
+}
+
+// levelOf return the name of the highest level not above v
+func levelOf(levels []level, v int64) string {
+	name := levels[0].Name
+	for _, l := range levels {
+		if v < l.Seconds {
+			break
 		}
-		return c
-	}, e.Key)
-	prefixes := getPrefixes(k, c.separators)
+		name = l.Name
+	}
+	return name
+}
+
+// keyPrefixes return the prefixes of the key of e, or the label of its
+// template if the keys are grouped by templates
+func (c *Counter) keyPrefixes(e *decoder.Entry) []string {
+	if c.patterns != nil {
+		return []string{c.keyTemplate(e.Key)}
+	}
+	return getPrefixes(c.normalizeKey(e.Key), c.separators, c.maxPrefixDepth)
+}
+
+func (c *Counter) countByKeyPrefix(e *decoder.Entry, prefixes []string) {
 	key := typeKey{
 		Type: e.Type,
 	}
+	idle := levelKey{}
+	if e.Idle >= 0 {
+		idle.Level = levelOf(c.idleLevels, e.Idle)
+	}
+	ttl := levelKey{Level: c.ttlLevelOf(e)}
+	enc := encodingKey{Type: e.Type, Encoding: e.Encoding}
+	longest := ""
 	for _, prefix := range prefixes {
 		if len(prefix) == 0 {
 			continue
 		}
+		if len(prefix) > len(longest) {
+			longest = prefix
+		}
 		key.Key = prefix
+		if c.sketch != nil {
+			c.sketch.add(key, e, idle.Level, ttl.Level)
+			continue
+		}
 		c.keyPrefixBytes[key] += e.Bytes
 		c.keyPrefixNum[key]++
+		if c.keyPrefixWhatIf != nil {
+			c.keyPrefixWhatIf[key] += e.WhatIfBytes
+		}
+
+		if e.Idle >= 0 {
+			idle.Prefix = prefix
+			c.keyPrefixIdleBytes[idle] += e.Bytes
+			c.keyPrefixIdleNum[idle]++
+		}
+
+		ttl.Prefix = prefix
+		c.keyPrefixTTLBytes[ttl] += e.Bytes
+		c.keyPrefixTTLNum[ttl]++
+
+		enc.Prefix = prefix
+		c.keyPrefixEncBytes[enc] += e.Bytes
+		c.keyPrefixEncNum[enc]++
+	}
+	c.expires.count(e, c.ctime, longest)
+	if c.spill != nil && c.prefixCounts() >= c.spill.maxPrefixes {
+		c.spillPrefixes()
+	}
+}
+
+// normalizeKey replace the identifiers of key with placeholders and reset
+// all numbers to 0, so that keys of the same shape have the same prefixes
+func (c *Counter) normalizeKey(key string) string {
+	// the identifiers are replaced by runes of the private use area until
+	// the numbers are reset, as placeholders may have digits
+	placeholders := []string{}
+	for i, n := range c.normalizers {
+		marker := string(rune(0xe000 + i))
+		if k := n.replace(key, marker); k != key {
+			key = k
+			placeholders = append(placeholders, marker, n.Placeholder)
+		}
+	}
+	key = strings.Map(func(c rune) rune {
+		if c >= 48 && c <= 57 { //48 == "0" 57 == "9"
+			return '0'
+		}
+		return c
+	}, key)
+	if len(placeholders) > 0 {
+		key = strings.NewReplacer(placeholders...).Replace(key)
 	}
+	return key
 }
 
 func (c *Counter) countBySlot(e *decoder.Entry) {
@@ -223,6 +767,176 @@ func (c *Counter) calcuLargestKeyPrefix(num int) {
 	}
 }
 
+// calcuIdleLevel keep the idle histograms of the largest key prefixes only
+func (c *Counter) calcuIdleLevel() {
+	c.idleLevelEntries = c.calcuLevelEntries(c.keyPrefixIdleBytes, c.keyPrefixIdleNum, func(name string) int {
+		return levelIndex(c.idleLevels, name)
+	})
+}
+
+// calcuTTLLevel keep the TTL histograms of the largest key prefixes only,
+// and find the TTL leaks among them
+func (c *Counter) calcuTTLLevel() {
+	c.ttlLevelEntries = c.calcuLevelEntries(c.keyPrefixTTLBytes, c.keyPrefixTTLNum, func(name string) int {
+		switch name {
+		case ttlLevelExpired:
+			return -1
+		case ttlLevelNone:
+			return len(c.ttlLevels)
+		}
+		return levelIndex(c.ttlLevels, name)
+	})
+
+	c.ttlLeaks = []*TTLLeakEntry{}
+	var leak *TTLLeakEntry
+	for _, entry := range c.ttlLevelEntries {
+		if leak == nil || leak.Prefix != entry.Prefix {
+			leak = &TTLLeakEntry{Prefix: entry.Prefix}
+			c.ttlLeaks = append(c.ttlLeaks, leak)
+		}
+		leak.Num += entry.Num
+		if entry.Level == ttlLevelNone {
+			leak.NoTTLNum = entry.Num
+			leak.NoTTLBytes = entry.Bytes
+		}
+	}
+	// a leak is a prefix that most keys expire but a minority never do
+	leaks := c.ttlLeaks[:0]
+	for _, leak := range c.ttlLeaks {
+		if leak.NoTTLNum > 0 && leak.NoTTLNum*2 < leak.Num {
+			leaks = append(leaks, leak)
+		}
+	}
+	sort.Slice(leaks, func(i, j int) bool {
+		return leaks[i].NoTTLBytes > leaks[j].NoTTLBytes
+	})
+	c.ttlLeaks = leaks
+}
+
+// calcuWhatIf get the what-if estimates of the largest key prefixes which
+// differ, by the difference
+func (c *Counter) calcuWhatIf() {
+	if c.keyPrefixWhatIf == nil {
+		return
+	}
+	c.whatIfEntries = []*WhatIfEntry{}
+	for _, p := range *c.largestKeyPrefixes {
+		whatIf := c.keyPrefixWhatIf[p.typeKey]
+		if whatIf == p.Bytes {
+			continue
+		}
+		c.whatIfEntries = append(c.whatIfEntries, &WhatIfEntry{
+			typeKey:     p.typeKey,
+			Bytes:       p.Bytes,
+			WhatIfBytes: whatIf,
+			Num:         p.Num,
+			Delta:       int64(whatIf) - int64(p.Bytes),
+		})
+	}
+	c.keyPrefixWhatIf = map[typeKey]uint64{}
+	sort.Slice(c.whatIfEntries, func(i, j int) bool {
+		a, b := c.whatIfEntries[i], c.whatIfEntries[j]
+		if abs(a.Delta) != abs(b.Delta) {
+			return abs(a.Delta) > abs(b.Delta)
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Type < b.Type
+	})
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// GetWhatIf return the memory use of all keys as if the redis config of
+// what-if applied, and the key prefixes of which it differs. ok is false
+// without what-if estimates.
+func (c *Counter) GetWhatIf() (bytes uint64, prefixes []*WhatIfEntry, ok bool) {
+	return c.whatIfBytes, c.whatIfEntries, c.whatIfEntries != nil
+}
+
+// calcuEncoding get the encoding entries of all keys and of the largest key
+// prefixes
+func (c *Counter) calcuEncoding() {
+	largest := map[string]bool{}
+	for _, p := range *c.largestKeyPrefixes {
+		largest[p.Key] = true
+	}
+	c.encodingEntries = encodingEntries(c.encodingBytes, c.encodingNum, nil)
+	c.prefixEncEntries = encodingEntries(c.keyPrefixEncBytes, c.keyPrefixEncNum, largest)
+}
+
+// encodingEntries get the entries of bytes and nums sorted by prefix, type
+// and encoding, only of the prefixes if not nil
+func encodingEntries(bytes, nums map[encodingKey]uint64, prefixes map[string]bool) []*EncodingEntry {
+	res := []*EncodingEntry{}
+	for key, b := range bytes {
+		if prefixes == nil || prefixes[key.Prefix] {
+			res = append(res, &EncodingEntry{
+				encodingKey: key,
+				Bytes:       b,
+				Num:         nums[key],
+			})
+		}
+		delete(bytes, key)
+		delete(nums, key)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if a.Prefix != b.Prefix {
+			return a.Prefix < b.Prefix
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Encoding < b.Encoding
+	})
+	return res
+}
+
+// calcuLevelEntries return the level histograms of the largest key prefixes
+// sorted by prefix and level, bytes and nums are cleared
+func (c *Counter) calcuLevelEntries(bytes, nums map[levelKey]uint64, index func(string) int) []*LevelEntry {
+	largest := map[string]bool{}
+	for _, p := range *c.largestKeyPrefixes {
+		largest[p.Key] = true
+	}
+	res := []*LevelEntry{}
+	for key, b := range bytes {
+		if largest[key.Prefix] {
+			res = append(res, &LevelEntry{
+				levelKey: key,
+				Bytes:    b,
+				Num:      nums[key],
+			})
+		}
+		delete(bytes, key)
+		delete(nums, key)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if a.Prefix != b.Prefix {
+			return a.Prefix < b.Prefix
+		}
+		return index(a.Level) < index(b.Level)
+	})
+	return res
+}
+
+func levelIndex(levels []level, name string) int {
+	for i, l := range levels {
+		if l.Name == name {
+			return i
+		}
+	}
+	return len(levels)
+}
+
 type entryHeap []*decoder.Entry
 
 func (h entryHeap) Len() int {
@@ -247,6 +961,80 @@ func (h *entryHeap) Push(e interface{}) {
 	*h = append(*h, e.(*decoder.Entry))
 }
 
+// freqHeap is a min heap of entries by LFU counter
+type freqHeap []*decoder.Entry
+
+func (h freqHeap) Len() int {
+	return len(h)
+}
+func (h freqHeap) Less(i, j int) bool {
+	if h[i].Freq == h[j].Freq {
+		return h[i].Bytes < h[j].Bytes
+	}
+	return h[i].Freq < h[j].Freq
+}
+func (h freqHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *freqHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+func (h *freqHeap) Push(e interface{}) {
+	*h = append(*h, e.(*decoder.Entry))
+}
+
+type levelKey struct {
+	Prefix string
+	Level  string
+}
+
+// LevelEntry record value by LRU idle level or TTL level of a key prefix
+type LevelEntry struct {
+	levelKey
+	Bytes uint64
+	Num   uint64
+}
+
+// TTLLeakEntry record a key prefix that most keys have a TTL but some never expire
+type TTLLeakEntry struct {
+	Prefix     string
+	Num        uint64
+	NoTTLNum   uint64
+	NoTTLBytes uint64
+}
+
+// WhatIfEntry record the memory use of a key prefix as if the redis config
+// of what-if applied
+type WhatIfEntry struct {
+	typeKey
+	Bytes       uint64
+	WhatIfBytes uint64
+	Num         uint64
+	// Delta is WhatIfBytes - Bytes, negative if bytes are saved
+	Delta int64
+}
+
+// encodingKey is a type and an encoding of keys of a prefix, the prefix is
+// empty for all keys
+type encodingKey struct {
+	Prefix   string
+	Type     string
+	Encoding string
+}
+
+// EncodingEntry record value by type and encoding of a key prefix
+type EncodingEntry struct {
+	encodingKey
+	Bytes uint64
+	Num   uint64
+}
+
 type typeKey struct {
 	Type string
 	Key  string
@@ -259,6 +1047,11 @@ type PrefixEntry struct {
 	typeKey
 	Bytes uint64
 	Num   uint64
+	// the bytes of the keys are at least Bytes-BytesError and at most Bytes,
+	// and there are Num-NumError keys at least, both errors are 0 unless the
+	// prefixes are counted by a sketch
+	BytesError uint64 `json:",omitempty"`
+	NumError   uint64 `json:",omitempty"`
 }
 
 func (h prefixHeap) Len() int {
@@ -320,13 +1113,15 @@ func removeDuplicatesUnordered(elements []string) []string {
 	return result
 }
 
-func getPrefixes(s, sep string) []string {
+// getPrefixes return the cumulative prefixes of s split at any of sep, the
+// first depth ones only if depth is positive
+func getPrefixes(s, sep string, depth int) []string {
 	res := []string{}
 	sepIdx := strings.IndexAny(s, sep)
 	if sepIdx < 0 {
 		res = append(res, s)
 	}
-	for sepIdx > -1 {
+	for sepIdx > -1 && (depth <= 0 || len(res) < depth) {
 		r := s[:sepIdx+1]
 		if len(res) > 0 {
 			r = res[len(res)-1] + s[:sepIdx+1]
@@ -366,6 +1161,11 @@ func (h slotHeap) Len() int {
 	return len(h)
 }
 func (h slotHeap) Less(i, j int) bool {
+	// slots of the same size are ordered by slot, to report them in the
+	// same order on each run
+	if h[i].Size == h[j].Size {
+		return h[i].Slot < h[j].Slot
+	}
 	return h[i].Size > h[j].Size
 }
 func (h slotHeap) Swap(i, j int) {
@@ -16,6 +16,8 @@ package dump
 
 import (
 	"container/heap"
+	"sort"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,13 +34,27 @@ func TestGetLargestKeyPrefixes(t *testing.T) {
 		FieldOfLargestElem: "test",
 	}
 	c := NewCounter()
-	c.countByKeyPrefix(e)
+	c.countByKeyPrefix(e, c.keyPrefixes(e))
 	c.calcuLargestKeyPrefix(1)
 	for _, p := range c.GetLargestKeyPrefixes() {
 		assert.Equal(t, "RELATIONSFOLLOWERIDS0000000000", p.Key)
 	}
 }
 
+func TestSetKeyPrefix(t *testing.T) {
+	c := NewCounter()
+	c.SetKeyPrefix(".|_", "_", 2)
+	e := &decoder.Entry{Key: "feed.user_timeline|42.items", Bytes: 10, Type: "list"}
+	c.countByKeyPrefix(e, c.keyPrefixes(e))
+	c.calcuLargestKeyPrefix(10)
+	keys := []string{}
+	for _, p := range c.GetLargestKeyPrefixes() {
+		keys = append(keys, p.Key)
+	}
+	sort.Strings(keys)
+	assert.Equal(t, []string{"feed", "feed.user_timeline"}, keys)
+}
+
 func TestSlotHeap(t *testing.T) {
 	var test slotHeap
 	                    //0  1  2  3  4  5  6
@@ -61,3 +77,132 @@ func TestSlotHeap(t *testing.T) {
 		}
 	}
 }
+
+func TestCountByIdle(t *testing.T) {
+	c := NewCounter()
+	c.coldIdle = 7 * 24 * 3600
+	for i, idle := range []int64{-1, 0, 3600, 8 * 24 * 3600} {
+		e := &decoder.Entry{
+			Key:   "session:" + string(rune('a'+i)),
+			Bytes: 10,
+			Type:  "string",
+			Idle:  idle,
+			Freq:  -1,
+		}
+		c.count(e)
+	}
+	c.calcuLargestKeyPrefix(10)
+	c.calcuIdleLevel()
+
+	assert.Equal(t, uint64(1), c.GetColdNum()["string"])
+	assert.Equal(t, uint64(10), c.GetColdBytes()["string"])
+	levels := []string{}
+	for _, entry := range c.GetIdleLevelCount()["session"] {
+		levels = append(levels, entry.Level)
+	}
+	assert.Equal(t, []string{"0s", "1h", "7d"}, levels)
+	assert.Empty(t, c.GetHottestEntries(10))
+}
+
+func TestCountByTTL(t *testing.T) {
+	c := NewCounter()
+	c.ctime = 1000
+	expiries := []int64{0, 999 * 1000, 1030 * 1000, 1000*1000 + 3600*1000, 1100 * 1000}
+	for i, expiry := range expiries {
+		e := &decoder.Entry{
+			Key:    "token:" + string(rune('a'+i)),
+			Bytes:  10,
+			Type:   "string",
+			Idle:   -1,
+			Freq:   -1,
+			Expiry: expiry,
+		}
+		c.count(e)
+	}
+	c.calcuLargestKeyPrefix(10)
+	c.calcuTTLLevel()
+
+	assert.Equal(t, uint64(1), c.GetNoTTLNum()["string"])
+	assert.Equal(t, uint64(1), c.GetExpiredNum()["string"])
+	levels := []string{}
+	for _, entry := range c.GetTTLLevelCount()["token"] {
+		levels = append(levels, entry.Level)
+	}
+	assert.Equal(t, []string{"expired", "0s", "1m", "1h", "none"}, levels)
+
+	leaks := c.GetTTLLeaks()
+	if assert.Len(t, leaks, 1) {
+		assert.Equal(t, "token", leaks[0].Prefix)
+		assert.Equal(t, uint64(5), leaks[0].Num)
+		assert.Equal(t, uint64(1), leaks[0].NoTTLNum)
+	}
+}
+
+func TestCountByExpire(t *testing.T) {
+	c := NewCounter()
+	c.ctime = 1000
+	for i := 0; i < 2000; i++ {
+		c.count(&decoder.Entry{Key: "wave:" + strconv.Itoa(i), Bytes: 10, Type: "string", Idle: -1, Freq: -1, Expiry: 1500 * 1000})
+	}
+	for i := 0; i < 100; i++ {
+		c.count(&decoder.Entry{Key: "calm:" + strconv.Itoa(i), Bytes: 10, Type: "string", Idle: -1, Freq: -1, Expiry: int64(1000+i*60) * 1000})
+	}
+	c.calcu()
+
+	spikes := c.GetExpireSpikes()
+	if assert.Len(t, spikes, 1) {
+		assert.Equal(t, "second", spikes[0].Unit)
+		assert.Equal(t, int64(1500), spikes[0].Time)
+		assert.Equal(t, uint64(2000), spikes[0].Num)
+		assert.Equal(t, "wave", spikes[0].Prefixes[0].Key)
+	}
+	assert.Len(t, c.GetExpireTimeline(), 100)
+}
+
+func TestCountByEncoding(t *testing.T) {
+	c := NewCounter()
+	c.count(&decoder.Entry{Key: "user:1", Bytes: 100, Type: "hash", Encoding: "listpack", Idle: -1, Freq: -1})
+	c.count(&decoder.Entry{Key: "user:2", Bytes: 100, Type: "hash", Encoding: "listpack", Idle: -1, Freq: -1})
+	c.count(&decoder.Entry{Key: "user:3", Bytes: 5000, Type: "hash", Encoding: "hashtable", Idle: -1, Freq: -1})
+	c.count(&decoder.Entry{Key: "count", Bytes: 16, Type: "string", Encoding: "int", Idle: -1, Freq: -1})
+	c.calcu()
+
+	all := c.GetEncodingCount()
+	if assert.Len(t, all, 3) {
+		assert.Equal(t, "hashtable", all[0].Encoding)
+		assert.Equal(t, uint64(5000), all[0].Bytes)
+		assert.Equal(t, "listpack", all[1].Encoding)
+		assert.Equal(t, uint64(2), all[1].Num)
+		assert.Equal(t, "string", all[2].Type)
+	}
+	user := c.GetKeyPrefixEncodingCount()["user"]
+	if assert.Len(t, user, 2) {
+		assert.Equal(t, "hashtable", user[0].Encoding)
+		assert.Equal(t, uint64(1), user[0].Num)
+		assert.Equal(t, uint64(200), user[1].Bytes)
+	}
+}
+
+func TestCountByDB(t *testing.T) {
+	for _, perDB := range []bool{false, true} {
+		c := NewCounter()
+		c.SetPerDB(perDB)
+		c.count(&decoder.Entry{Key: "a:1", DB: 0, Bytes: 10, Type: "string", Idle: -1, Freq: -1})
+		c.count(&decoder.Entry{Key: "b:1", DB: 5, Bytes: 20, Type: "hash", Idle: -1, Freq: -1})
+		c.count(&decoder.Entry{Key: "b:2", DB: 5, Bytes: 30, Type: "hash", Idle: -1, Freq: -1})
+		c.calcu()
+
+		assert.Equal(t, []int{0, 5}, c.GetDBs())
+		dbc := c.GetDBCounter(5)
+		assert.Equal(t, !perDB, dbc.TotalsOnly())
+		assert.Equal(t, map[string]uint64{"hash": 50}, dbc.typeBytes)
+		assert.Equal(t, map[string]uint64{"hash": 2}, dbc.typeNum)
+		if perDB {
+			assert.Len(t, dbc.GetLargestEntries(10), 2)
+			assert.NotEmpty(t, dbc.GetLargestKeyPrefixes())
+		} else {
+			assert.Empty(t, dbc.GetLargestEntries(10))
+			assert.Empty(t, dbc.GetLargestKeyPrefixes())
+		}
+	}
+}
@@ -3,9 +3,8 @@ package dump
 import (
 	"container/heap"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 
 	"github.com/dongmx/rdb"
 	"github.com/urfave/cli"
@@ -17,12 +16,13 @@ func Dump(path string) (map[string]interface{}, error) {
 	var data map[string]interface{}
 	decoder := decoder.NewDecoder()
 	go func() {
-		f, err := os.Open(path)
+		f, err := Open(path)
 		defer close(decoder.Entries)
 		if err != nil {
 			fmt.Printf("open rdbfile err: %v\n", err)
 			return
 		}
+		defer f.Close()
 		err = rdb.Decode(f, decoder)
 		if err != nil {
 			fmt.Printf("decode rdbfile err: %v\n", err)
@@ -30,62 +30,260 @@ func Dump(path string) (map[string]interface{}, error) {
 		}
 	}()
 	cnt := NewCounter()
-	cnt.Count(decoder.Entries)
-	filename := filepath.Base(path)
-	data = getData(filename, cnt)
+	cnt.CountDecoder(decoder)
+	data = getData(instanceName(path), cnt)
 	return data, nil
 }
 
 // ToCliWriter dump rdb file statistical information to STDOUT.
-func ToCliWriter(cli *cli.Context) {
-	if cli.NArg() < 1 {
-		fmt.Fprintln(cli.App.ErrWriter, " requires at least 1 argument")
-		return
+func ToCliWriter(c *cli.Context) error {
+	if c.NArg() < 1 {
+		fmt.Fprintln(c.App.ErrWriter, " requires at least 1 argument")
+		return nil
+	}
+	// the counter of the first rdbfile checks the flags before any output
+	cnt, err := newCounter(c)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("configure err: %v", err), 1)
 	}
 
 	// parse rdbfile
-	fmt.Fprintln(cli.App.Writer, "[")
-	nargs := cli.NArg()
+	fmt.Fprintln(c.App.Writer, "[")
+	nargs := c.NArg()
 	for i := 0; i < nargs; i++ {
-		file := cli.Args().Get(i)
+		file := c.Args().Get(i)
 		decoder := decoder.NewDecoder()
-		go Decode(cli, decoder, file)
-		cnt := NewCounter()
-		cnt.Count(decoder.Entries)
-		filename := filepath.Base(file)
-		data := getData(filename, cnt)
+		if i > 0 {
+			if cnt, err = newCounter(c); err != nil {
+				return cli.NewExitError(fmt.Sprintf("configure err: %v", err), 1)
+			}
+		}
+		go Decode(c, decoder, file)
+		cnt.CountDecoder(decoder)
+		data := getData(instanceName(file), cnt)
 		data["MemoryUse"] = decoder.GetUsedMem()
 		data["CTime"] = decoder.GetTimestamp()
 		jsonBytes, _ := json.MarshalIndent(data, "", "    ")
-		fmt.Fprint(cli.App.Writer, string(jsonBytes))
+		fmt.Fprint(c.App.Writer, string(jsonBytes))
 		if i == nargs-1 {
-			fmt.Fprintln(cli.App.Writer)
+			fmt.Fprintln(c.App.Writer)
 		} else {
-			fmt.Fprintln(cli.App.Writer, ",")
+			fmt.Fprintln(c.App.Writer, ",")
+		}
+	}
+	fmt.Fprintln(c.App.Writer, "]")
+	return nil
+}
+
+// newCounter return a Counter configured by command line flags, or an error
+// if a flag is invalid
+func newCounter(c *cli.Context) (*Counter, error) {
+	cnt := NewCounter()
+	cnt.SetKeyPrefix(c.String("separators"), c.String("segment-chars"), c.Int("max-prefix-depth"))
+	normalizers, err := ParseKeyNormalizers(c.StringSlice("normalize"))
+	if err != nil {
+		return nil, fmt.Errorf("normalize: %v", err)
+	}
+	cnt.SetKeyNormalizers(normalizers)
+	switch mode := c.String("group-by"); mode {
+	case "", "prefix":
+	case "pattern":
+		cnt.SetKeyPatterns(c.Int("max-patterns"), c.Float64("pattern-similarity"))
+	default:
+		return nil, fmt.Errorf("group-by: unknown mode %q", mode)
+	}
+	if mode := c.String("prefix-counting"); cnt.patterns != nil && mode != PrefixCountingMemory {
+		fmt.Fprintf(c.App.ErrWriter, "prefix-counting err: %s is ignored, the templates of --group-by pattern are bounded already\n", mode)
+	} else if err := cnt.SetPrefixCounting(mode, c.Int("max-prefixes"), c.String("spill-dir")); err != nil {
+		fmt.Fprintf(c.App.ErrWriter, "prefix-counting err: %v, the prefixes are counted in memory\n", err)
+	}
+	if path := c.String("rules"); path != "" {
+		rules, err := LoadKeyRules(path)
+		if err != nil {
+			return nil, fmt.Errorf("rules: %v", err)
 		}
+		cnt.SetKeyRules(rules)
 	}
-	fmt.Fprintln(cli.App.Writer, "]")
+	cnt.SetPerDB(c.Bool("per-db"))
+	calibrate(c, cnt)
+	if c.IsSet("cold-days") {
+		cnt.coldIdle = int64(c.Int("cold-days")) * 24 * 3600
+	}
+	return cnt, nil
 }
 
 // Decode ...
 func Decode(c *cli.Context, decoder *decoder.Decoder, filepath string) {
-	f, err := os.Open(filepath)
+	if dbs := c.IntSlice("db"); len(dbs) > 0 {
+		decoder.FilterDB(dbs...)
+	}
+	if err := configureDecoder(c, decoder); err != nil {
+		fmt.Fprintf(c.App.ErrWriter, "configure err: %v\n", err)
+		decoder.AddError(err)
+		close(decoder.Entries)
+		return
+	}
+	decodeFile(c, decoder, filepath)
+}
+
+// decodeFile decode the rdbfile or append only file at filepath to a
+// configured decoder
+func decodeFile(c *cli.Context, decoder *decoder.Decoder, filepath string) {
+	src, err := findAOF(filepath)
 	if err != nil {
 		fmt.Fprintf(c.App.ErrWriter, "open rdbfile err: %v\n", err)
+		decoder.AddError(err)
 		close(decoder.Entries)
 		return
 	}
-	err = rdb.Decode(f, decoder)
+	if src != nil {
+		if err := decodeAOF(src, decoder, c.Bool("verify"), c.Bool("tolerant"), c.App.ErrWriter); err != nil {
+			err = explainDecodeError(err)
+			fmt.Fprintf(c.App.ErrWriter, "decode append only file err: %v\n", err)
+			decoder.AddError(err)
+			close(decoder.Entries)
+		}
+		return
+	}
+
+	f, err := Open(filepath)
 	if err != nil {
+		fmt.Fprintf(c.App.ErrWriter, "open rdbfile err: %v\n", err)
+		decoder.AddError(err)
+		close(decoder.Entries)
+		return
+	}
+	defer f.Close()
+	_, _, err = rdb.DecodeWithOptions(f, decoder, decodeOptions(decoder, c.Bool("verify"), c.Bool("tolerant")))
+	if err != nil {
+		err = explainDecodeError(err)
 		fmt.Fprintf(c.App.ErrWriter, "decode rdbfile err: %v\n", err)
+		decoder.AddError(err)
 		close(decoder.Entries)
 		return
 	}
 }
 
+// explainDecodeError add to err how to decode the other keys if they can be
+func explainDecodeError(err error) error {
+	if errors.Is(err, rdb.ErrModuleV1) {
+		return fmt.Errorf("%v, --tolerant skips the key and decodes the others", err)
+	}
+	return err
+}
+
+// configureDecoder set the memory model, the maxmemory policy and the redis
+// config of what-if estimates of the command line flags to d
+func configureDecoder(c *cli.Context, d *decoder.Decoder) error {
+	if path := c.String("redis-conf"); path != "" {
+		conf, err := decoder.LoadRedisConfig(path)
+		if err != nil {
+			return err
+		}
+		d.SetRedisConfig(conf)
+	}
+	if policy := c.String("maxmemory-policy"); policy != "" {
+		if err := d.SetMaxmemoryPolicy(policy); err != nil {
+			return err
+		}
+	}
+	d.SetListCompressDepth(int64(c.Int("list-compress-depth")))
+	if c.String("memory-model") == "" {
+		return nil
+	}
+	mm, err := decoder.LoadMemoryModel(c.String("memory-model"))
+	if err != nil {
+		return err
+	}
+	return d.SetMemoryModel(mm)
+}
+
+// errorAdder records the errors of a tolerant decoding
+type errorAdder interface {
+	AddError(err error)
+}
+
+// decodeOptions return the options of the rdb parser, in tolerant mode the
+// keys which fail to decode are skipped and the errors are added to d
+func decodeOptions(d errorAdder, verify, tolerant bool) rdb.Options {
+	opts := rdb.Options{Verify: verify}
+	if tolerant {
+		opts.OnError = func(err *rdb.DecodeError) {
+			d.AddError(err)
+		}
+	}
+	return opts
+}
+
+// maxErrors is the number of decoding errors shown
+const maxErrors = 100
+
 func getData(filename string, cnt *Counter) map[string]interface{} {
-	data := make(map[string]interface{})
+	data := getSummaryData(cnt)
 	data["CurrentInstance"] = filename
+	data["Metadata"] = cnt.GetMetadata()
+	data["MemoryModel"] = cnt.GetMemoryModel()
+	if cal := cnt.GetCalibration(); cal != nil {
+		data["Calibration"] = cal
+	}
+	if bytes, prefixes, ok := cnt.GetWhatIf(); ok {
+		data["WhatIfBytes"] = bytes
+		data["WhatIfPrefixes"] = prefixes
+	}
+	if groups, teams, ok := cnt.GetGroups(); ok {
+		data["Groups"] = groups
+		data["Teams"] = teams
+	}
+
+	lenLevelCount := map[string][]*PrefixEntry{}
+	for _, entry := range cnt.GetLenLevelCount() {
+		lenLevelCount[entry.Type] = append(lenLevelCount[entry.Type], entry)
+	}
+	data["LenLevelCount"] = lenLevelCount
+
+	data["HottestKeys"] = cnt.GetHottestEntries(100)
+	data["IdleLevelCount"] = cnt.GetIdleLevelCount()
+	data["ColdDays"] = cnt.coldIdle / (24 * 3600)
+	data["ColdBytes"] = cnt.GetColdBytes()
+	data["ColdNum"] = cnt.GetColdNum()
+
+	data["TTLLevelCount"] = cnt.GetTTLLevelCount()
+	data["TTLLeaks"] = cnt.GetTTLLeaks()
+	data["NoTTLBytes"] = cnt.GetNoTTLBytes()
+	data["NoTTLNum"] = cnt.GetNoTTLNum()
+	data["ExpiredBytes"] = cnt.GetExpiredBytes()
+	data["ExpiredNum"] = cnt.GetExpiredNum()
+	data["ExpireSpikes"] = cnt.GetExpireSpikes()
+	data["ExpireTimeline"] = cnt.GetExpireTimeline()
+
+	dbs := []map[string]interface{}{}
+	for _, db := range cnt.GetDBs() {
+		dbc := cnt.GetDBCounter(db)
+		dbData := getTotalsData(dbc)
+		if !dbc.TotalsOnly() {
+			dbData = getSummaryData(dbc)
+		}
+		dbData["DB"] = db
+		dbs = append(dbs, dbData)
+	}
+	data["DBs"] = dbs
+	data["PerDB"] = cnt.perDB
+
+	getSlotData(data, cnt)
+
+	errs := cnt.GetErrors()
+	data["ErrorNum"] = len(errs)
+	if len(errs) > maxErrors {
+		errs = errs[:maxErrors]
+	}
+	data["Errors"] = errs
+
+	return data
+}
+
+// getSummaryData get totals, type breakdowns, largest keys and prefixes
+func getSummaryData(cnt *Counter) map[string]interface{} {
+	data := make(map[string]interface{})
 	data["LargestKeys"] = cnt.GetLargestEntries(100)
 
 	largestKeyPrefixesByType := map[string][]*PrefixEntry{}
@@ -97,7 +295,17 @@ func getData(filename string, cnt *Counter) map[string]interface{} {
 		largestKeyPrefixesByType[entry.Type] = append(largestKeyPrefixesByType[entry.Type], entry)
 	}
 	data["LargestKeyPrefixes"] = largestKeyPrefixesByType
+	data["KeyPrefixEncodingCount"] = cnt.GetKeyPrefixEncodingCount()
+	for key, val := range getTotalsData(cnt) {
+		data[key] = val
+	}
+	return data
+}
 
+// getTotalsData get the totals by type and encoding of cnt
+func getTotalsData(cnt *Counter) map[string]interface{} {
+	data := make(map[string]interface{})
+	data["EncodingCount"] = cnt.GetEncodingCount()
 	data["TypeBytes"] = cnt.typeBytes
 	data["TypeNum"] = cnt.typeNum
 	totalNum := uint64(0)
@@ -108,15 +316,16 @@ func getData(filename string, cnt *Counter) map[string]interface{} {
 	for _, v := range cnt.typeBytes {
 		totalBytes += v
 	}
+	// the keyspace overhead is not of any key or type, but of the databases
+	overhead := cnt.GetKeyspaceOverhead()
+	data["KeyspaceOverhead"] = overhead
 	data["TotleNum"] = totalNum
-	data["TotleBytes"] = totalBytes
+	data["TotleBytes"] = totalBytes + overhead
 
-	lenLevelCount := map[string][]*PrefixEntry{}
-	for _, entry := range cnt.GetLenLevelCount() {
-		lenLevelCount[entry.Type] = append(lenLevelCount[entry.Type], entry)
-	}
-	data["LenLevelCount"] = lenLevelCount
+	return data
+}
 
+func getSlotData(data map[string]interface{}, cnt *Counter) {
 	var slotBytesHeap slotHeap
 	for slot, length := range cnt.slotBytes {
 		heap.Push(&slotBytesHeap, &SlotEntry{
@@ -153,6 +362,4 @@ func getData(filename string, cnt *Counter) map[string]interface{} {
 
 	data["SlotBytes"] = slotBytes
 	data["SlotNums"] = slotNums
-
-	return data
 }
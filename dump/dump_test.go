@@ -0,0 +1,270 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"testing"
+
+	"github.com/dongmx/rdb"
+	"github.com/dongmx/rdb/crc64"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+	"github.com/xueqiu/rdr/decoder"
+)
+
+// rdbString is a string value type with key and value
+func rdbString(key, value string) []byte {
+	b := []byte{0, byte(len(key))}
+	b = append(b, key...)
+	b = append(b, byte(len(value)))
+	return append(b, value...)
+}
+
+// testRDB is a rdb of version 9 with the strings first, second and third in
+// database 0, the value of second is a listpack hash of garbage if corrupt.
+// It returns the rdb and the offset of second.
+func testRDB(corrupt bool) ([]byte, int64) {
+	b := []byte("REDIS0009\xfe\x00")
+	b = append(b, rdbString("first", "1")...)
+	offset := int64(len(b))
+	if corrupt {
+		b = append(b, 16, 6)
+		b = append(b, "second"...)
+		b = append(b, 12)
+		b = append(b, "\x0c\x00\x00\x00\x02\x00\xee\xee\xee\xee\xee\xee"...)
+	} else {
+		b = append(b, rdbString("second", "2")...)
+	}
+	b = append(b, rdbString("third", "3")...)
+	b = append(b, 0xff)
+	checksum := make([]byte, 8)
+	binary.LittleEndian.PutUint64(checksum, crc64.Digest(b))
+	return append(b, checksum...), offset
+}
+
+// decodeTestRDB decode b with decodeOptions and return the keys, the errors
+// and the checksum
+func decodeTestRDB(t *testing.T, b []byte, verify, tolerant bool) ([]string, []*decoder.ErrorEntry, uint64) {
+	d := decoder.NewDecoder()
+	_, checksum, err := rdb.DecodeWithOptions(bytes.NewReader(b), d, decodeOptions(d, verify, tolerant))
+	assert.NoError(t, err)
+	keys := []string{}
+	// EndRDB closes the entries
+	for e := range d.Entries {
+		keys = append(keys, e.Key)
+	}
+	return keys, d.GetErrors(), checksum
+}
+
+func TestDecodeTolerant(t *testing.T) {
+	b, offset := testRDB(true)
+	_, _, err := rdb.DecodeWithOptions(bytes.NewReader(b), decoder.NewDecoder(), rdb.Options{})
+	assert.Error(t, err)
+
+	keys, errs, _ := decodeTestRDB(t, b, false, true)
+	assert.Equal(t, []string{"first", "third"}, keys)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "second", errs[0].Key)
+		assert.True(t, errs[0].Offset >= offset && errs[0].Offset < offset+22, "offset %d", errs[0].Offset)
+		assert.Equal(t, int64(len("\x10\x06second\x0c")+12), errs[0].Skipped)
+	}
+}
+
+func TestDecodeTolerantChecksum(t *testing.T) {
+	clean, _ := testRDB(false)
+	_, _, checksum := decodeTestRDB(t, clean, true, false)
+	assert.NotZero(t, checksum)
+	_, errs, tolerantChecksum := decodeTestRDB(t, clean, true, true)
+	assert.Empty(t, errs)
+	assert.Equal(t, checksum, tolerantChecksum)
+
+	// the bytes read again to resynchronise are digested once
+	corrupt, _ := testRDB(true)
+	_, errs, checksum = decodeTestRDB(t, corrupt, true, true)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, binary.LittleEndian.Uint64(corrupt[len(corrupt)-8:]), checksum)
+}
+
+func TestDecodeTolerantTruncated(t *testing.T) {
+	b, _ := testRDB(false)
+	keys, errs, _ := decodeTestRDB(t, b[:len(b)-12], false, true)
+	assert.Equal(t, []string{"first", "second"}, keys)
+	assert.Len(t, errs, 1)
+}
+
+func TestDecodeTolerantLargeKey(t *testing.T) {
+	// a list of 40 elements of 1mb, the last one a corrupt LZF string, so
+	// that the key is larger than the bytes the parse keeps to rewind
+	b := []byte("REDIS0009\xfe\x00")
+	b = append(b, rdbString("first", "1")...)
+	offset := int64(len(b))
+	b = append(b, 1, 3)
+	b = append(b, "big"...)
+	b = append(b, 40)
+	element := bytes.Repeat([]byte("x"), 1<<20)
+	for i := 0; i < 39; i++ {
+		b = append(b, 0x80, 0, 0x10, 0, 0)
+		b = append(b, element...)
+	}
+	b = append(b, 0xc3, 4, 0x3f, 0xff, 0xff, 0xff, 0xff)
+	b = append(b, rdbString("third", "3")...)
+	b = append(b, 0xff)
+	checksum := make([]byte, 8)
+	binary.LittleEndian.PutUint64(checksum, crc64.Digest(b))
+	b = append(b, checksum...)
+
+	keys, errs, sum := decodeTestRDB(t, b, true, true)
+	assert.Equal(t, []string{"first", "third"}, keys)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "big", errs[0].Key)
+		assert.Equal(t, int64(len(b)-len("\x00\x05third\x013\xff")-8)-offset, errs[0].Skipped)
+	}
+	assert.Equal(t, binary.LittleEndian.Uint64(checksum), sum)
+}
+
+// auxRDB is a rdb of version 9 with the aux fields and the string k of 40
+// bytes, without checksum
+func auxRDB(aux ...string) []byte {
+	b := []byte("REDIS0009")
+	for i := 0; i+1 < len(aux); i += 2 {
+		b = append(b, 0xfa, byte(len(aux[i])))
+		b = append(b, aux[i]...)
+		b = append(b, byte(len(aux[i+1])))
+		b = append(b, aux[i+1]...)
+	}
+	b = append(b, 0xfe, 0)
+	b = append(b, rdbString("k", string(bytes.Repeat([]byte("v"), 40)))...)
+	b = append(b, 0xff)
+	return append(b, make([]byte, 8)...)
+}
+
+// countAux count auxRDB of the aux fields
+func countAux(aux ...string) (*decoder.Decoder, *Counter) {
+	d := decoder.NewDecoder()
+	go rdb.Decode(bytes.NewReader(auxRDB(aux...)), d)
+	cnt := NewCounter()
+	cnt.CountDecoder(d)
+	return d, cnt
+}
+
+func TestAuxFields(t *testing.T) {
+	aux := []string{"redis-ver", "3.0.7", "redis-bits", "32", "ctime", "1600000000", "used-mem", "1000000", "lua", "return 1"}
+	d, cnt := countAux(aux...)
+	metadata := []string{}
+	for _, f := range cnt.GetMetadata() {
+		metadata = append(metadata, f.Key, f.Value)
+	}
+	assert.Equal(t, aux, metadata)
+
+	// 32 bits pointers and the strings of redis before 3.2
+	name, model := d.GetMemoryModel()
+	assert.Equal(t, "redis3-jemalloc (32 bits)", name)
+	assert.Equal(t, "redis3-jemalloc (32 bits)", cnt.GetMemoryModel())
+	assert.Equal(t, uint64(4), model.PointerSize)
+	assert.Equal(t, []uint64{8}, model.SdsHeaders)
+	assert.Equal(t, int64(39), model.EmbstrLimit)
+
+	d, wide := countAux("redis-ver", "3.0.7", "redis-bits", "64")
+	_, model = d.GetMemoryModel()
+	assert.Equal(t, uint64(8), model.PointerSize)
+	assert.True(t, cnt.typeBytes["string"] < wide.typeBytes["string"])
+
+	// the value of 40 bytes is embedded in its robj since redis 3.2
+	d, embstr := countAux("redis-ver", "3.2.0", "redis-bits", "64")
+	name, model = d.GetMemoryModel()
+	assert.Equal(t, "redis3-jemalloc", name)
+	assert.Equal(t, []uint64{1, 3, 5, 9, 17}, model.SdsHeaders)
+	assert.Equal(t, int64(44), model.EmbstrLimit)
+	assert.True(t, embstr.typeBytes["string"] < wide.typeBytes["string"])
+}
+
+func TestKeyspaceOverhead(t *testing.T) {
+	// database 0 sized for 100 keys and 1 expire, database 1 for 2 keys
+	b := []byte("REDIS0009\xfe\x00\xfb\x40\x64\x01")
+	b = append(b, rdbString("a", "1")...)
+	b = append(b, 0xfc)
+	expiry := make([]byte, 8)
+	binary.LittleEndian.PutUint64(expiry, 4102444800000)
+	b = append(b, expiry...)
+	b = append(b, rdbString("b", "2")...)
+	b = append(b, rdbString("c", "3")...)
+	b = append(b, "\xfe\x01\xfb\x02\x00"...)
+	b = append(b, rdbString("d", "4")...)
+	b = append(b, rdbString("e", "5")...)
+	b = append(b, 0xff)
+	b = append(b, make([]byte, 8)...)
+
+	d := decoder.NewDecoder()
+	go rdb.Decode(bytes.NewReader(b), d)
+	cnt := NewCounter()
+	cnt.CountDecoder(d)
+	_, model := d.GetMemoryModel()
+	dict := func(buckets uint64) uint64 {
+		return model.Dict + buckets*model.PointerSize
+	}
+
+	keyspaces := d.GetKeyspaces()
+	if assert.Len(t, keyspaces, 2) {
+		assert.Equal(t, decoder.Keyspace{DB: 0, Keys: 3, Expires: 1, DBSize: 100, ExpiresSize: 1,
+			MainDictBytes: dict(128), ExpiresDictBytes: dict(2)}, *keyspaces[0])
+		assert.Equal(t, decoder.Keyspace{DB: 1, Keys: 2, DBSize: 2,
+			MainDictBytes: dict(4)}, *keyspaces[1])
+	}
+
+	jsonBytes, err := json.Marshal(getData("dump.rdb", cnt))
+	if !assert.NoError(t, err) {
+		return
+	}
+	var data struct {
+		KeyspaceOverhead uint64
+		DBs              []struct {
+			DB               int
+			KeyspaceOverhead uint64
+		}
+	}
+	assert.NoError(t, json.Unmarshal(jsonBytes, &data))
+	assert.Equal(t, dict(128)+dict(2)+dict(4), data.KeyspaceOverhead)
+	if assert.Len(t, data.DBs, 2) {
+		assert.Equal(t, dict(128)+dict(2), data.DBs[0].KeyspaceOverhead)
+		assert.Equal(t, 1, data.DBs[1].DB)
+		assert.Equal(t, dict(4), data.DBs[1].KeyspaceOverhead)
+	}
+}
+
+// runCommand run action with args parsed by the counter flags, and return
+// its output
+func runCommand(action func(*cli.Context) error, args ...string) (string, error) {
+	app := cli.NewApp()
+	out := &bytes.Buffer{}
+	app.Writer, app.ErrWriter = out, out
+	set := flag.NewFlagSet("command", flag.ContinueOnError)
+	for _, f := range []cli.Flag{
+		cli.StringSliceFlag{Name: "normalize"},
+		cli.StringFlag{Name: "group-by"},
+		cli.StringFlag{Name: "prefix-counting", Value: PrefixCountingMemory},
+		cli.StringFlag{Name: "rules"},
+	} {
+		f.Apply(set)
+	}
+	if err := set.Parse(args); err != nil {
+		return "", err
+	}
+	err := action(cli.NewContext(app, set, nil))
+	return out.String(), err
+}
@@ -0,0 +1,187 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"sort"
+
+	"github.com/xueqiu/rdr/decoder"
+)
+
+// Many keys expiring in the same second make redis spend a long time in the
+// active expire cycle. Expirations after the snapshot are bucketed by second
+// (within expireWindow seconds) and by minute, a bucket holding much more keys
+// than the average is reported as a spike.
+
+const (
+	// at most maxExpirePrefixes key prefixes are kept in a bucket, the rest
+	// are counted as otherExpirePrefix
+	maxExpirePrefixes = 10
+	otherExpirePrefix = "other"
+	// at most maxExpireSpikes spikes are reported for each unit
+	maxExpireSpikes = 20
+)
+
+// expireUnit is the length of expire buckets
+type expireUnit struct {
+	Name    string
+	Seconds int64
+	// a bucket is a spike if it holds at least minNum keys
+	minNum uint64
+}
+
+var (
+	expireSecond = expireUnit{Name: "second", Seconds: 1, minNum: 1000}
+	expireMinute = expireUnit{Name: "minute", Seconds: 60, minNum: 10000}
+)
+
+// ExpireBucket record keys expiring in the same second or minute
+type ExpireBucket struct {
+	// Time is the unix time of the start of the bucket
+	Time  int64
+	Num   uint64
+	Bytes uint64
+}
+
+type expireBucket struct {
+	ExpireBucket
+	prefixes map[typeKey]*PrefixEntry
+}
+
+// ExpireSpike is a bucket holding much more expiring keys than the average
+type ExpireSpike struct {
+	Unit string
+	ExpireBucket
+	// Prefixes of the keys, largest first
+	Prefixes []*PrefixEntry
+}
+
+// expireCounter count expirations of keys by time
+type expireCounter struct {
+	// second buckets are kept for expireWindow seconds after the snapshot only
+	expireWindow int64
+	// a bucket is a spike if it holds spikeRatio times more keys than the average
+	spikeRatio uint64
+	seconds    map[int64]*expireBucket
+	minutes    map[int64]*expireBucket
+	spikes     []*ExpireSpike
+	timeline   []*ExpireBucket
+}
+
+func newExpireCounter() *expireCounter {
+	return &expireCounter{
+		expireWindow: 24 * 3600,
+		spikeRatio:   10,
+		seconds:      map[int64]*expireBucket{},
+		minutes:      map[int64]*expireBucket{},
+	}
+}
+
+// count e that expires after ctime, prefix is the key prefix of e
+func (ec *expireCounter) count(e *decoder.Entry, ctime int64, prefix string) {
+	at := e.Expiry / 1000
+	if ctime <= 0 || e.Expiry <= 0 || at < ctime {
+		return
+	}
+	if at-ctime < ec.expireWindow {
+		ec.add(ec.seconds, at, e, prefix)
+	}
+	ec.add(ec.minutes, at-at%expireMinute.Seconds, e, prefix)
+}
+
+func (ec *expireCounter) add(buckets map[int64]*expireBucket, t int64, e *decoder.Entry, prefix string) {
+	b, ok := buckets[t]
+	if !ok {
+		b = &expireBucket{
+			ExpireBucket: ExpireBucket{Time: t},
+			prefixes:     map[typeKey]*PrefixEntry{},
+		}
+		buckets[t] = b
+	}
+	b.Num++
+	b.Bytes += e.Bytes
+
+	key := typeKey{Type: e.Type, Key: prefix}
+	p, ok := b.prefixes[key]
+	if !ok {
+		if len(b.prefixes) >= maxExpirePrefixes {
+			key.Key = otherExpirePrefix
+		}
+		if p, ok = b.prefixes[key]; !ok {
+			p = &PrefixEntry{typeKey: key}
+			b.prefixes[key] = p
+		}
+	}
+	p.Num++
+	p.Bytes += e.Bytes
+}
+
+// calcu find the spikes and the timeline of minutes within expireWindow
+func (ec *expireCounter) calcu(ctime int64) {
+	ec.spikes = append(ec.findSpikes(ec.seconds, expireSecond, ctime), ec.findSpikes(ec.minutes, expireMinute, ctime)...)
+
+	ec.timeline = []*ExpireBucket{}
+	for t, b := range ec.minutes {
+		if t-ctime < ec.expireWindow {
+			bucket := b.ExpireBucket
+			ec.timeline = append(ec.timeline, &bucket)
+		}
+	}
+	sort.Slice(ec.timeline, func(i, j int) bool {
+		return ec.timeline[i].Time < ec.timeline[j].Time
+	})
+}
+
+// findSpikes return the largest buckets holding at least spikeRatio times
+// more keys than the average bucket between ctime and the last expiration
+func (ec *expireCounter) findSpikes(buckets map[int64]*expireBucket, unit expireUnit, ctime int64) []*ExpireSpike {
+	var total uint64
+	var last int64
+	for t, b := range buckets {
+		total += b.Num
+		if t > last {
+			last = t
+		}
+	}
+	spikes := []*ExpireSpike{}
+	if total == 0 {
+		return spikes
+	}
+	n := uint64((last-ctime)/unit.Seconds + 1)
+	for _, b := range buckets {
+		if b.Num < unit.minNum || b.Num*n < ec.spikeRatio*total {
+			continue
+		}
+		spike := &ExpireSpike{
+			Unit:         unit.Name,
+			ExpireBucket: b.ExpireBucket,
+		}
+		for _, p := range b.prefixes {
+			spike.Prefixes = append(spike.Prefixes, p)
+		}
+		sort.Sort(sort.Reverse(prefixHeap(spike.Prefixes)))
+		spikes = append(spikes, spike)
+	}
+	sort.Slice(spikes, func(i, j int) bool {
+		if spikes[i].Num == spikes[j].Num {
+			return spikes[i].Time < spikes[j].Time
+		}
+		return spikes[i].Num > spikes[j].Num
+	})
+	if len(spikes) > maxExpireSpikes {
+		spikes = spikes[:maxExpireSpikes]
+	}
+	return spikes
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+)
+
+// StdinPath is the path to read a rdbfile from stdin
+const StdinPath = "-"
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	lz4Magic  = []byte{0x04, 0x22, 0x4d, 0x18}
+)
+
+// rdbReader is a rdbfile being decompressed on the fly
+type rdbReader struct {
+	io.Reader
+	closers []func() error
+}
+
+// Close the decompressor and the file
+func (r *rdbReader) Close() error {
+	var err error
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if e := r.closers[i](); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Open a rdbfile, or stdin if path is StdinPath. Files compressed by gzip,
+// zstd or lz4 are detected by magic bytes and decompressed on the fly.
+func Open(path string) (io.ReadCloser, error) {
+	r := &rdbReader{}
+	var f *os.File
+	if path == StdinPath {
+		f = os.Stdin
+	} else {
+		var err error
+		f, err = os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		r.closers = append(r.closers, f.Close)
+	}
+
+	br := bufio.NewReader(f)
+	// a short file is left to the rdb decoder to complain about
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.Reader = gr
+		r.closers = append(r.closers, gr.Close)
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.Reader = zr
+		r.closers = append(r.closers, func() error { zr.Close(); return nil })
+	case bytes.HasPrefix(magic, lz4Magic):
+		r.Reader = lz4.NewReader(br)
+	default:
+		r.Reader = br
+	}
+	return r, nil
+}
+
+// instanceName return the name of a rdbfile shown to users
+func instanceName(path string) string {
+	if path == StdinPath {
+		return "stdin"
+	}
+	return filepath.Base(path)
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dongmx/rdb"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+	"github.com/stretchr/testify/assert"
+	"github.com/xueqiu/rdr/decoder"
+)
+
+// compressor return a writer compressing to w, nil if the file is plain
+type compressor func(w io.Writer) (io.WriteCloser, error)
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "input")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	b, _ := testRDB(false)
+
+	gz := func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }
+	tests := []struct {
+		name     string
+		stdin    bool
+		compress compressor
+	}{
+		{name: "plain"},
+		{name: "stdin", stdin: true},
+		{name: "gzip", compress: gz},
+		{name: "zstd", compress: func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }},
+		{name: "lz4", compress: func(w io.Writer) (io.WriteCloser, error) { return lz4.NewWriter(w), nil }},
+		{name: "gzip stdin", stdin: true, compress: gz},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, "dump.rdb")
+		content := b
+		if test.compress != nil {
+			buf := &bytes.Buffer{}
+			w, err := test.compress(buf)
+			if !assert.NoError(t, err, test.name) {
+				continue
+			}
+			w.Write(b)
+			assert.NoError(t, w.Close(), test.name)
+			content = buf.Bytes()
+			assert.NotEqual(t, b[:4], content[:4], test.name)
+		}
+		if !assert.NoError(t, ioutil.WriteFile(path, content, 0644), test.name) {
+			continue
+		}
+		stdin := os.Stdin
+		if test.stdin {
+			if os.Stdin, err = os.Open(path); !assert.NoError(t, err, test.name) {
+				os.Stdin = stdin
+				continue
+			}
+			path = StdinPath
+		}
+
+		keys := []string{}
+		f, err := Open(path)
+		if assert.NoError(t, err, test.name) {
+			d := decoder.NewDecoder()
+			assert.NoError(t, rdb.Decode(f, d), test.name)
+			assert.NoError(t, f.Close(), test.name)
+			for e := range d.Entries {
+				keys = append(keys, e.Key)
+			}
+		}
+		if test.stdin {
+			os.Stdin.Close()
+			os.Stdin = stdin
+		}
+		assert.Equal(t, []string{"first", "second", "third"}, keys, test.name)
+	}
+}
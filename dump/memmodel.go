@@ -0,0 +1,46 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/urfave/cli"
+	"github.com/xueqiu/rdr/decoder"
+)
+
+// PrintMemoryModel print the sizes of a built-in memory model as JSON
+func PrintMemoryModel(c *cli.Context) error {
+	if c.NArg() != 1 {
+		fmt.Fprintln(c.App.ErrWriter, "memory-model requires 1 argument")
+		cli.ShowCommandHelp(c, "memory-model")
+		return nil
+	}
+	name := c.Args().First()
+	major, allocator, err := decoder.ParseProfile(name)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	bits := c.Int("bits")
+	if bits != 32 && bits != 64 {
+		return cli.NewExitError(fmt.Sprintf("invalid bits %d", bits), 1)
+	}
+	mm := decoder.BuiltinMemoryModel(major, bits, allocator)
+	mm.Profile = name
+	jsonBytes, _ := json.MarshalIndent(mm, "", "    ")
+	fmt.Fprintln(c.App.Writer, string(jsonBytes))
+	return nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// KeyNormalizer replace the identifiers of keys matching a pattern with a
+// placeholder, so that keys of the same shape have the same prefixes
+type KeyNormalizer struct {
+	Name        string
+	Placeholder string
+	re          *regexp.Regexp
+	// valid reports whether a match is an identifier, all are if nil
+	valid func(token string) bool
+}
+
+// NewKeyNormalizer return a KeyNormalizer of the identifiers matching
+// pattern, which are replaced with {name}
+func NewKeyNormalizer(name, pattern string) (*KeyNormalizer, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern of normalizer %s: %v", name, err)
+	}
+	return &KeyNormalizer{Name: name, Placeholder: "{" + name + "}", re: re}, nil
+}
+
+func mustKeyNormalizer(name, pattern string, valid func(string) bool) *KeyNormalizer {
+	n, err := NewKeyNormalizer(name, pattern)
+	if err != nil {
+		panic(err)
+	}
+	n.valid = valid
+	return n
+}
+
+// epoch seconds and milliseconds from 2001 to 2100 are timestamps
+const (
+	minTimestamp = 1000000000
+	maxTimestamp = 4102444800
+)
+
+// builtinNormalizers are tried in this order, the more specific first
+var builtinNormalizers = []*KeyNormalizer{
+	mustKeyNormalizer("email", `[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`, nil),
+	mustKeyNormalizer("uuid", `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`, nil),
+	mustKeyNormalizer("ip", `[0-9]{1,3}(\.[0-9]{1,3}){3}`, func(token string) bool {
+		for _, octet := range strings.Split(token, ".") {
+			if n, _ := strconv.Atoi(octet); n > 255 {
+				return false
+			}
+		}
+		return true
+	}),
+	mustKeyNormalizer("ts", `[0-9]{10}([0-9]{3})?`, func(token string) bool {
+		n, _ := strconv.ParseInt(token, 10, 64)
+		if len(token) == 13 {
+			n /= 1000
+		}
+		return n >= minTimestamp && n < maxTimestamp
+	}),
+	mustKeyNormalizer("hex", `[0-9a-fA-F]{16,}`, func(token string) bool {
+		return strings.ContainsAny(token, "0123456789") && strings.ContainsAny(token, "abcdefABCDEF")
+	}),
+	mustKeyNormalizer("b64", `[A-Za-z0-9+/]{20,}={0,2}`, func(token string) bool {
+		return strings.ContainsAny(token, "0123456789") &&
+			strings.ContainsAny(token, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") &&
+			strings.ContainsAny(token, "abcdefghijklmnopqrstuvwxyz")
+	}),
+}
+
+// ParseKeyNormalizers return the built-in normalizers of names, or custom
+// ones given as NAME=REGEX, all built-in ones if names is empty and none if
+// it is "none"
+func ParseKeyNormalizers(names []string) ([]*KeyNormalizer, error) {
+	if len(names) == 0 {
+		return builtinNormalizers, nil
+	}
+	res := []*KeyNormalizer{}
+	for _, name := range names {
+		if name == "none" {
+			continue
+		}
+		if i := strings.IndexByte(name, '='); i > 0 {
+			n, err := NewKeyNormalizer(name[:i], name[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, n)
+			continue
+		}
+		n := builtinNormalizer(name)
+		if n == nil {
+			return nil, fmt.Errorf("unknown normalizer %q", name)
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+func builtinNormalizer(name string) *KeyNormalizer {
+	for _, n := range builtinNormalizers {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// Normalize replace the identifiers in key with the placeholder, an
+// identifier must not be part of a longer alphanumeric run
+func (n *KeyNormalizer) Normalize(key string) string {
+	return n.replace(key, n.Placeholder)
+}
+
+func (n *KeyNormalizer) replace(key, placeholder string) string {
+	var b strings.Builder
+	last := 0
+	for start := 0; start < len(key); {
+		loc := n.re.FindStringIndex(key[start:])
+		if loc == nil || loc[0] == loc[1] {
+			break
+		}
+		i, j := start+loc[0], start+loc[1]
+		if boundary(key, i-1) && boundary(key, j) && (n.valid == nil || n.valid(key[i:j])) {
+			b.WriteString(key[last:i])
+			b.WriteString(placeholder)
+			last, start = j, j
+		} else {
+			start = i + 1
+		}
+	}
+	if last == 0 {
+		return key
+	}
+	b.WriteString(key[last:])
+	return b.String()
+}
+
+// boundary reports whether key has no letter or digit at i
+func boundary(key string, i int) bool {
+	if i < 0 || i >= len(key) {
+		return true
+	}
+	c := key[i]
+	return !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestNormalizeKey(t *testing.T) {
+	c := NewCounter()
+	for key, expected := range map[string]string{
+		"session:3fa85f64-5717-4562-b3fc-2c963f66afa6": "session:{uuid}",
+		"cache:ab12cd34ef56ab78cd90":                   "cache:{hex}",
+		"login:john.doe@example.com:count":             "login:{email}:count",
+		"rate:10.0.0.12:1700000000123":                 "rate:{ip}:{ts}",
+		"token:AbCdEfGh12345678IjKlMnOp":               "token:{b64}",
+		"RELATIONSFOLLOWERIDS6420000664":               "RELATIONSFOLLOWERIDS0000000000",
+		"user:123:deadline":                            "user:000:deadline",
+	} {
+		assert.Equal(t, expected, c.normalizeKey(key))
+	}
+
+	normalizers, err := ParseKeyNormalizers([]string{"sku=SKU[0-9]+", "uuid"})
+	if assert.NoError(t, err) {
+		c.SetKeyNormalizers(normalizers)
+		assert.Equal(t, "item:{sku}:ab00cd00ef00ab00cd00", c.normalizeKey("item:SKU123:ab12cd34ef56ab78cd90"))
+	}
+	_, err = ParseKeyNormalizers([]string{"guid"})
+	assert.Error(t, err)
+}
+
+func TestNormalizeFlag(t *testing.T) {
+	for _, action := range []func(*cli.Context) error{ToCliWriter, Advise} {
+		out, err := runCommand(action, "--normalize", "uuid", "--normalize", "bogus", "dump.rdb")
+		if exit, ok := err.(cli.ExitCoder); assert.True(t, ok, "%v", err) {
+			assert.Equal(t, 1, exit.ExitCode())
+			assert.Equal(t, `configure err: normalize: unknown normalizer "bogus"`, exit.Error())
+		}
+		assert.Empty(t, out)
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Keys without separators, such as userfeed123456 or camelCase keys, have
+// no useful prefixes. The pattern miner groups them instead by the shape of
+// their tokens, the way Drain clusters log lines into templates: a key is
+// split into words, numbers and separators, and joins the most similar
+// template of the same length and first word, the words which differ
+// becoming wildcards, or starts a new template.
+
+const (
+	// patternNum replaces the numbers of keys
+	patternNum = "{num}"
+	// patternAny replaces the words which differ between keys of a template
+	patternAny = "{*}"
+	// PatternOther is the template of keys which fit no template once the
+	// miner keeps its maximum number of templates
+	PatternOther = "{other}"
+)
+
+// patternCluster is a template and the keys of it
+type patternCluster struct {
+	label  string
+	tokens []string
+}
+
+func (pc *patternCluster) template() string {
+	return strings.Join(pc.tokens, "")
+}
+
+// patternMiner learn the templates of keys in bounded memory
+type patternMiner struct {
+	// at most maxPatterns templates are kept, the keys which would start a
+	// new one are counted as PatternOther
+	maxPatterns int
+	// a key joins a template if at least similarity of their words are equal
+	similarity float64
+	clusters   []*patternCluster
+	// clusters by number of tokens and first word
+	leaves map[string][]*patternCluster
+}
+
+func newPatternMiner(maxPatterns int, similarity float64) *patternMiner {
+	return &patternMiner{
+		maxPatterns: maxPatterns,
+		similarity:  similarity,
+		leaves:      map[string][]*patternCluster{},
+	}
+}
+
+// add key to its template and return the label of the template, the
+// templates are only final once all keys are added, see template
+func (m *patternMiner) add(key string) string {
+	tokens := tokenizeKey(key)
+	leaf := strconv.Itoa(len(tokens))
+	for _, t := range tokens {
+		if isPatternWord(t) {
+			if !isPatternParam(t) {
+				leaf += " " + t
+			}
+			break
+		}
+	}
+
+	var best *patternCluster
+	bestSim := -1.0
+	for _, pc := range m.leaves[leaf] {
+		if sim := m.match(pc.tokens, tokens); sim > bestSim {
+			best, bestSim = pc, sim
+		}
+	}
+	if best != nil && bestSim >= m.similarity {
+		for i, t := range best.tokens {
+			if t != tokens[i] {
+				best.tokens[i] = patternAny
+			}
+		}
+		return best.label
+	}
+	if len(m.clusters) >= m.maxPatterns {
+		return PatternOther
+	}
+	pc := &patternCluster{label: patternLabel(len(m.clusters)), tokens: tokens}
+	m.clusters = append(m.clusters, pc)
+	m.leaves[leaf] = append(m.leaves[leaf], pc)
+	return pc.label
+}
+
+// match return the ratio of the equal words of template and tokens, -1 if
+// their separators differ. Placeholders are not counted, as keys of a
+// template often differ by a single word next to them.
+func (m *patternMiner) match(template, tokens []string) float64 {
+	words, equal := 0, 0
+	for i, t := range template {
+		k := tokens[i]
+		switch {
+		case !isPatternWord(t) || !isPatternWord(k):
+			if t != k {
+				return -1
+			}
+		case t == patternAny, isPatternParam(t) && t == k:
+		default:
+			words++
+			if t == k {
+				equal++
+			}
+		}
+	}
+	if words == 0 {
+		return 1
+	}
+	return float64(equal) / float64(words)
+}
+
+// labels of templates start with a rune of the private use area, so that
+// they are told from the keys
+const patternLabelPrefix = "\U000f0000"
+
+func patternLabel(i int) string {
+	return patternLabelPrefix + strconv.Itoa(i)
+}
+
+// template return the final template of label, or label if it is not one
+func (m *patternMiner) template(label string) string {
+	if !strings.HasPrefix(label, patternLabelPrefix) {
+		return label
+	}
+	i, err := strconv.Atoi(label[len(patternLabelPrefix):])
+	if err != nil || i >= len(m.clusters) {
+		return label
+	}
+	return m.clusters[i].template()
+}
+
+// tokenizeKey split key into words, numbers as patternNum and separators, a
+// word is a run of lower case letters, or of upper case letters, or both as
+// a camelCase word. Placeholders of normalizers such as {uuid} are words.
+func tokenizeKey(key string) []string {
+	tokens := []string{}
+	for i := 0; i < len(key); {
+		c := key[i]
+		j := i + 1
+		switch {
+		case isDigit(c):
+			for j < len(key) && isDigit(key[j]) {
+				j++
+			}
+			tokens = append(tokens, patternNum)
+			i = j
+			continue
+		case isLower(c):
+			for j < len(key) && isLower(key[j]) {
+				j++
+			}
+		case isUpper(c):
+			for j < len(key) && isUpper(key[j]) {
+				j++
+			}
+			if j < len(key) && isLower(key[j]) {
+				// the last upper case letter of HTTPServer starts Server
+				if j-i > 1 {
+					j--
+				} else {
+					for j < len(key) && isLower(key[j]) {
+						j++
+					}
+				}
+			}
+		case c == '{':
+			for j < len(key) && (isLower(key[j]) || isDigit(key[j])) {
+				j++
+			}
+			if j < len(key) && j > i+1 && key[j] == '}' {
+				j++
+			} else {
+				j = i + 1
+			}
+		}
+		tokens = append(tokens, key[i:j])
+		i = j
+	}
+	return tokens
+}
+
+// isPatternWord reports whether the token is a word, a number or a
+// placeholder rather than a separator
+func isPatternWord(t string) bool {
+	c := t[0]
+	return isDigit(c) || isLower(c) || isUpper(c) || c == '{' && len(t) > 2
+}
+
+// isPatternParam reports whether the token is a placeholder
+func isPatternParam(t string) bool {
+	return len(t) > 2 && t[0] == '{' && t[len(t)-1] == '}'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLower(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+func isUpper(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+// SetKeyPatterns group the keys by the templates learned from them rather
+// than by prefixes, at most maxPatterns templates are kept
+func (c *Counter) SetKeyPatterns(maxPatterns int, similarity float64) {
+	c.patterns = newPatternMiner(maxPatterns, similarity)
+}
+
+// keyTemplate add the key with its identifiers replaced to the templates and
+// return the label of its template
+func (c *Counter) keyTemplate(key string) string {
+	for _, n := range c.normalizers {
+		key = n.Normalize(key)
+	}
+	return c.patterns.add(key)
+}
+
+// resolvePatterns replace the labels of templates the keys are counted by
+// with the final templates, which may add up the counts of several labels
+func (c *Counter) resolvePatterns() {
+	m := c.patterns
+	bytes, num := map[typeKey]uint64{}, map[typeKey]uint64{}
+	for key, v := range c.keyPrefixBytes {
+		bytes[typeKey{Type: key.Type, Key: m.template(key.Key)}] += v
+	}
+	for key, v := range c.keyPrefixNum {
+		num[typeKey{Type: key.Type, Key: m.template(key.Key)}] += v
+	}
+	c.keyPrefixBytes, c.keyPrefixNum = bytes, num
+	if c.keyPrefixWhatIf != nil {
+		whatIf := map[typeKey]uint64{}
+		for key, v := range c.keyPrefixWhatIf {
+			whatIf[typeKey{Type: key.Type, Key: m.template(key.Key)}] += v
+		}
+		c.keyPrefixWhatIf = whatIf
+	}
+	c.keyPrefixIdleBytes = resolveLevels(m, c.keyPrefixIdleBytes)
+	c.keyPrefixIdleNum = resolveLevels(m, c.keyPrefixIdleNum)
+	c.keyPrefixTTLBytes = resolveLevels(m, c.keyPrefixTTLBytes)
+	c.keyPrefixTTLNum = resolveLevels(m, c.keyPrefixTTLNum)
+	encBytes, encNum := map[encodingKey]uint64{}, map[encodingKey]uint64{}
+	for key, v := range c.keyPrefixEncBytes {
+		key.Prefix = m.template(key.Prefix)
+		encBytes[key] += v
+	}
+	for key, v := range c.keyPrefixEncNum {
+		key.Prefix = m.template(key.Prefix)
+		encNum[key] += v
+	}
+	c.keyPrefixEncBytes, c.keyPrefixEncNum = encBytes, encNum
+	for _, buckets := range []map[int64]*expireBucket{c.expires.seconds, c.expires.minutes} {
+		for _, b := range buckets {
+			prefixes := map[typeKey]*PrefixEntry{}
+			for key, p := range b.prefixes {
+				key.Key = m.template(key.Key)
+				if q, ok := prefixes[key]; ok {
+					q.Bytes += p.Bytes
+					q.Num += p.Num
+					continue
+				}
+				p.typeKey = key
+				prefixes[key] = p
+			}
+			b.prefixes = prefixes
+		}
+	}
+}
+
+func resolveLevels(m *patternMiner, levels map[levelKey]uint64) map[levelKey]uint64 {
+	res := map[levelKey]uint64{}
+	for key, v := range levels {
+		key.Prefix = m.template(key.Prefix)
+		res[key] += v
+	}
+	return res
+}
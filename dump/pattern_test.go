@@ -0,0 +1,69 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+	"github.com/xueqiu/rdr/decoder"
+)
+
+func TestKeyPatterns(t *testing.T) {
+	assert.Equal(t, []string{"userfeed", "{num}"}, tokenizeKey("userfeed123456"))
+	assert.Equal(t, []string{"get", "HTTP", "Server", ":", "{uuid}"}, tokenizeKey("getHTTPServer:{uuid}"))
+
+	c := NewCounter()
+	c.SetKeyPatterns(3, 0.6)
+	c.SetPerDB(true)
+	for i := 0; i < 10; i++ {
+		c.count(&decoder.Entry{Key: "userfeed" + strconv.Itoa(100000+i), Bytes: 10, Type: "list", Idle: -1, Freq: -1})
+	}
+	for _, name := range []string{"alice", "bob", "carol"} {
+		c.count(&decoder.Entry{Key: "user:" + name + ":feed", Bytes: 20, Type: "list", Idle: -1, Freq: -1})
+	}
+	c.count(&decoder.Entry{Key: "user:1:like", Bytes: 1, Type: "list", Idle: -1, Freq: -1})
+	c.count(&decoder.Entry{Key: "user:1:follow", Bytes: 1, Type: "list", Idle: -1, Freq: -1})
+	c.count(&decoder.Entry{Key: "session:3fa85f64-5717-4562-b3fc-2c963f66afa6", Bytes: 5, Type: "list", Idle: -1, Freq: -1})
+	c.calcu()
+
+	// the database has the templates of all keys
+	for _, cnt := range []*Counter{c, c.GetDBCounter(0)} {
+		prefixes := map[string]uint64{}
+		for _, p := range cnt.GetLargestKeyPrefixes() {
+			prefixes[p.Key] = p.Bytes
+		}
+		assert.Equal(t, map[string]uint64{
+			"userfeed{num}":   100,
+			"user:{*}:feed":   60,
+			"user:{num}:like": 1,
+			PatternOther:      6,
+		}, prefixes)
+	}
+	assert.Len(t, c.patterns.clusters, 3)
+}
+
+func TestGroupByFlag(t *testing.T) {
+	for _, action := range []func(*cli.Context) error{ToCliWriter, Advise} {
+		out, err := runCommand(action, "--group-by", "template", "dump.rdb")
+		if exit, ok := err.(cli.ExitCoder); assert.True(t, ok, "%v", err) {
+			assert.Equal(t, 1, exit.ExitCode())
+			assert.Equal(t, `configure err: group-by: unknown mode "template"`, exit.Error())
+		}
+		assert.Empty(t, out)
+	}
+}
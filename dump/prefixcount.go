@@ -0,0 +1,601 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/xueqiu/rdr/decoder"
+)
+
+// The key prefixes are counted in maps of every distinct prefix until the
+// largest ones are picked, which does not fit in memory for instances of
+// hundreds of millions of keys. The sketch mode keeps the heavy hitters only,
+// with error bounds, and the disk mode spills the maps to sorted runs which
+// are merged at the end.
+
+// modes of counting key prefixes
+const (
+	PrefixCountingMemory = "memory"
+	PrefixCountingSketch = "sketch"
+	PrefixCountingDisk   = "disk"
+)
+
+// SetPrefixCounting set how key prefixes are counted: in memory, in a
+// space-saving sketch of maxPrefixes prefixes, or spilled to dir whenever
+// maxPrefixes prefixes are in memory
+func (c *Counter) SetPrefixCounting(mode string, maxPrefixes int, dir string) error {
+	if mode != PrefixCountingMemory && maxPrefixes <= 0 {
+		return fmt.Errorf("max prefixes %d of %s counting is not positive", maxPrefixes, mode)
+	}
+	c.sketch, c.spill = nil, nil
+	switch mode {
+	case PrefixCountingMemory:
+	case PrefixCountingSketch:
+		c.sketch = newPrefixSketch(maxPrefixes)
+	case PrefixCountingDisk:
+		c.spill = &prefixSpill{dir: dir, maxPrefixes: maxPrefixes}
+	default:
+		return fmt.Errorf("unknown prefix counting %q", mode)
+	}
+	return nil
+}
+
+// sketchItem is a prefix monitored by the sketch
+type sketchItem struct {
+	sketchKey
+	bytes  uint64
+	num    uint64
+	whatIf uint64
+	// the counts of the evicted prefix the item took over, bytes is at
+	// most bytesErr more than the bytes of the keys of the prefix
+	bytesErr uint64
+	numErr   uint64
+	// bytes and number of the keys counted since the prefix was taken in,
+	// by idle level, TTL level and encoding
+	idle     map[string][2]uint64
+	ttl      map[string][2]uint64
+	encoding map[string][2]uint64
+	index    int
+}
+
+// sketchKey is a prefix of a database, or of all databases if db is -1
+type sketchKey struct {
+	db int
+	typeKey
+}
+
+type sketchHeap []*sketchItem
+
+func (h sketchHeap) Len() int           { return len(h) }
+func (h sketchHeap) Less(i, j int) bool { return h[i].bytes < h[j].bytes }
+func (h sketchHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *sketchHeap) Push(x interface{}) {
+	item := x.(*sketchItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *sketchHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[0 : n-1]
+	return item
+}
+
+// spaceSaving count the largest key prefixes by bytes in the space-saving
+// way: once capacity prefixes are monitored, a new prefix replaces the
+// smallest one and takes over its counts as the error of its own
+type spaceSaving struct {
+	capacity int
+	items    map[sketchKey]*sketchItem
+	smallest sketchHeap
+}
+
+func (s *spaceSaving) add(key sketchKey, e *decoder.Entry, idle, ttl string) {
+	item, ok := s.items[key]
+	switch {
+	case ok:
+	case len(s.items) < s.capacity:
+		item = &sketchItem{sketchKey: key}
+		heap.Push(&s.smallest, item)
+		s.items[key] = item
+	default:
+		item = s.smallest[0]
+		delete(s.items, item.sketchKey)
+		item.sketchKey = key
+		item.bytesErr, item.numErr = item.bytes, item.num
+		item.idle, item.ttl, item.encoding = nil, nil, nil
+		s.items[key] = item
+	}
+	item.bytes += e.Bytes
+	item.num++
+	item.whatIf += e.WhatIfBytes
+	if e.Idle >= 0 {
+		item.idle = addCount(item.idle, idle, e.Bytes)
+	}
+	item.ttl = addCount(item.ttl, ttl, e.Bytes)
+	item.encoding = addCount(item.encoding, e.Encoding, e.Bytes)
+	heap.Fix(&s.smallest, item.index)
+}
+
+func addCount(counts map[string][2]uint64, key string, bytes uint64) map[string][2]uint64 {
+	if counts == nil {
+		counts = map[string][2]uint64{}
+	}
+	v := counts[key]
+	counts[key] = [2]uint64{v[0] + bytes, v[1] + 1}
+	return counts
+}
+
+// prefixSketch is the prefixes of a counter in a sketch. The counters of the
+// databases share one sketch of the same capacity, so that the prefixes
+// take twice the capacity at most.
+type prefixSketch struct {
+	s  *spaceSaving
+	db int
+	// dbs is the sketch of the databases, nil in those
+	dbs *spaceSaving
+}
+
+func newPrefixSketch(capacity int) *prefixSketch {
+	return &prefixSketch{s: newSpaceSaving(capacity), db: -1}
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{
+		capacity: capacity,
+		items:    map[sketchKey]*sketchItem{},
+	}
+}
+
+// database return the sketch of the counter of database db
+func (ps *prefixSketch) database(db int) *prefixSketch {
+	if ps.dbs == nil {
+		ps.dbs = newSpaceSaving(ps.s.capacity)
+	}
+	return &prefixSketch{s: ps.dbs, db: db}
+}
+
+// add e to the counts of prefix key, idle and ttl are the levels of e
+func (ps *prefixSketch) add(key typeKey, e *decoder.Entry, idle, ttl string) {
+	ps.s.add(sketchKey{db: ps.db, typeKey: key}, e, idle, ttl)
+}
+
+// calcuSketch move the monitored prefixes into the largest prefixes, and
+// their histograms into the histograms of the counter
+func (c *Counter) calcuSketch(num int) {
+	for key, item := range c.sketch.s.items {
+		if key.db != c.sketch.db {
+			continue
+		}
+		heap.Push(c.largestKeyPrefixes, &PrefixEntry{
+			typeKey:    item.typeKey,
+			Bytes:      item.bytes,
+			Num:        item.num,
+			BytesError: item.bytesErr,
+			NumError:   item.numErr,
+		})
+		if c.keyPrefixWhatIf != nil {
+			c.keyPrefixWhatIf[item.typeKey] = item.whatIf
+		}
+		if c.largestKeyPrefixes.Len() > num {
+			heap.Pop(c.largestKeyPrefixes)
+		}
+		for level, v := range item.idle {
+			k := levelKey{Prefix: item.Key, Level: level}
+			c.keyPrefixIdleBytes[k] += v[0]
+			c.keyPrefixIdleNum[k] += v[1]
+		}
+		for level, v := range item.ttl {
+			k := levelKey{Prefix: item.Key, Level: level}
+			c.keyPrefixTTLBytes[k] += v[0]
+			c.keyPrefixTTLNum[k] += v[1]
+		}
+		for enc, v := range item.encoding {
+			k := encodingKey{Prefix: item.Key, Type: item.Type, Encoding: enc}
+			c.keyPrefixEncBytes[k] += v[0]
+			c.keyPrefixEncNum[k] += v[1]
+		}
+	}
+	c.sketch = newPrefixSketch(c.sketch.s.capacity)
+}
+
+// kinds of spilled records, in the order they are merged
+const (
+	spillPrefix byte = iota
+	spillIdle
+	spillTTL
+	spillEncoding
+)
+
+// spillRecord is an entry of the prefix maps, of keys a, b and c
+type spillRecord struct {
+	kind    byte
+	a, b, c string
+	values  [3]uint64
+}
+
+func (r *spillRecord) less(o *spillRecord) bool {
+	if r.kind != o.kind {
+		return r.kind < o.kind
+	}
+	if r.a != o.a {
+		return r.a < o.a
+	}
+	if r.b != o.b {
+		return r.b < o.b
+	}
+	return r.c < o.c
+}
+
+func (r *spillRecord) sameKey(o *spillRecord) bool {
+	return r.kind == o.kind && r.a == o.a && r.b == o.b && r.c == o.c
+}
+
+// prefixSpill write the prefix maps to sorted runs in dir
+type prefixSpill struct {
+	dir         string
+	maxPrefixes int
+	runs        []string
+	// err is the first error writing a run, the prefix maps are pruned
+	// instead from then on
+	err error
+}
+
+// prefixCounts return the number of counts of the prefix maps, the bytes
+// and the number of keys of a prefix are one count
+func (c *Counter) prefixCounts() int {
+	return len(c.keyPrefixBytes) + len(c.keyPrefixIdleBytes) + len(c.keyPrefixTTLBytes) + len(c.keyPrefixEncBytes)
+}
+
+// prefixRecords return the prefix maps as sorted records
+func (c *Counter) prefixRecords() []*spillRecord {
+	records := make([]*spillRecord, 0, c.prefixCounts())
+	for key, b := range c.keyPrefixBytes {
+		records = append(records, &spillRecord{kind: spillPrefix, a: key.Type, b: key.Key,
+			values: [3]uint64{b, c.keyPrefixNum[key], c.keyPrefixWhatIf[key]}})
+	}
+	for key, b := range c.keyPrefixIdleBytes {
+		records = append(records, &spillRecord{kind: spillIdle, a: key.Prefix, b: key.Level,
+			values: [3]uint64{b, c.keyPrefixIdleNum[key]}})
+	}
+	for key, b := range c.keyPrefixTTLBytes {
+		records = append(records, &spillRecord{kind: spillTTL, a: key.Prefix, b: key.Level,
+			values: [3]uint64{b, c.keyPrefixTTLNum[key]}})
+	}
+	for key, b := range c.keyPrefixEncBytes {
+		records = append(records, &spillRecord{kind: spillEncoding, a: key.Prefix, b: key.Type, c: key.Encoding,
+			values: [3]uint64{b, c.keyPrefixEncNum[key]}})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].less(records[j])
+	})
+	return records
+}
+
+func (c *Counter) clearPrefixes() {
+	c.keyPrefixBytes = map[typeKey]uint64{}
+	c.keyPrefixNum = map[typeKey]uint64{}
+	if c.keyPrefixWhatIf != nil {
+		c.keyPrefixWhatIf = map[typeKey]uint64{}
+	}
+	c.keyPrefixIdleBytes = map[levelKey]uint64{}
+	c.keyPrefixIdleNum = map[levelKey]uint64{}
+	c.keyPrefixTTLBytes = map[levelKey]uint64{}
+	c.keyPrefixTTLNum = map[levelKey]uint64{}
+	c.keyPrefixEncBytes = map[encodingKey]uint64{}
+	c.keyPrefixEncNum = map[encodingKey]uint64{}
+}
+
+// spillPrefixes write the prefix maps to a run and clear them, or prune them
+// once a run fails to be written
+func (c *Counter) spillPrefixes() {
+	if c.spill.err == nil {
+		path, err := writeRun(c.spill.dir, c.prefixRecords())
+		if err == nil {
+			c.spill.runs = append(c.spill.runs, path)
+			c.clearPrefixes()
+			return
+		}
+		c.spill.err = err
+	}
+	c.prunePrefixes(c.spill.maxPrefixes / 2)
+}
+
+// prunePrefixes keep the largest prefixes by bytes with their histograms,
+// within limit counts
+func (c *Counter) prunePrefixes(limit int) {
+	counts, bytes := map[string]int{}, map[string]uint64{}
+	for key, b := range c.keyPrefixBytes {
+		counts[key.Key]++
+		bytes[key.Key] += b
+	}
+	for key := range c.keyPrefixIdleBytes {
+		counts[key.Prefix]++
+	}
+	for key := range c.keyPrefixTTLBytes {
+		counts[key.Prefix]++
+	}
+	for key := range c.keyPrefixEncBytes {
+		counts[key.Prefix]++
+	}
+	prefixes := make([]string, 0, len(bytes))
+	for prefix := range bytes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return bytes[prefixes[i]] > bytes[prefixes[j]]
+	})
+	kept := map[string]bool{}
+	for _, prefix := range prefixes {
+		if limit -= counts[prefix]; limit < 0 {
+			break
+		}
+		kept[prefix] = true
+	}
+
+	for key := range c.keyPrefixBytes {
+		if !kept[key.Key] {
+			delete(c.keyPrefixBytes, key)
+			delete(c.keyPrefixNum, key)
+			delete(c.keyPrefixWhatIf, key)
+		}
+	}
+	for _, levels := range []map[levelKey]uint64{c.keyPrefixIdleBytes, c.keyPrefixIdleNum, c.keyPrefixTTLBytes, c.keyPrefixTTLNum} {
+		for key := range levels {
+			if !kept[key.Prefix] {
+				delete(levels, key)
+			}
+		}
+	}
+	for _, encodings := range []map[encodingKey]uint64{c.keyPrefixEncBytes, c.keyPrefixEncNum} {
+		for key := range encodings {
+			if !kept[key.Prefix] {
+				delete(encodings, key)
+			}
+		}
+	}
+}
+
+func writeRun(dir string, records []*spillRecord) (string, error) {
+	f, err := ioutil.TempFile(dir, "rdr-prefixes-")
+	if err != nil {
+		return "", err
+	}
+	w := bufio.NewWriter(f)
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, r := range records {
+		w.WriteByte(r.kind)
+		for _, s := range []string{r.a, r.b, r.c} {
+			w.Write(buf[:binary.PutUvarint(buf, uint64(len(s)))])
+			w.WriteString(s)
+		}
+		for _, v := range r.values {
+			w.Write(buf[:binary.PutUvarint(buf, v)])
+		}
+	}
+	err = w.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// runReader read the records of a run in order, or of records if f is nil
+type runReader struct {
+	f       *os.File
+	r       *bufio.Reader
+	records []*spillRecord
+	head    *spillRecord
+}
+
+func (rr *runReader) next() error {
+	if rr.f == nil {
+		rr.head = nil
+		if len(rr.records) > 0 {
+			rr.head, rr.records = rr.records[0], rr.records[1:]
+		}
+		return nil
+	}
+	kind, err := rr.r.ReadByte()
+	if err == io.EOF {
+		rr.head = nil
+		return nil
+	} else if err != nil {
+		return err
+	}
+	rec := &spillRecord{kind: kind}
+	for _, s := range []*string{&rec.a, &rec.b, &rec.c} {
+		n, err := binary.ReadUvarint(rr.r)
+		if err != nil {
+			return err
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(rr.r, b); err != nil {
+			return err
+		}
+		*s = string(b)
+	}
+	for i := range rec.values {
+		if rec.values[i], err = binary.ReadUvarint(rr.r); err != nil {
+			return err
+		}
+	}
+	rr.head = rec
+	return nil
+}
+
+func (rr *runReader) close() {
+	if rr.f != nil {
+		rr.f.Close()
+	}
+}
+
+type runHeap []*runReader
+
+func (h runHeap) Len() int            { return len(h) }
+func (h runHeap) Less(i, j int) bool  { return h[i].head.less(h[j].head) }
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*runReader)) }
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+// mergeRuns call fn with the records of runs and of the sorted records in
+// order, those of the same key added up
+func mergeRuns(runs []string, records []*spillRecord, fn func(*spillRecord)) error {
+	readers := runHeap{}
+	defer func() {
+		for _, rr := range readers {
+			rr.close()
+		}
+	}()
+	if len(records) > 0 {
+		rr := &runReader{records: records}
+		rr.next()
+		readers = append(readers, rr)
+	}
+	for _, path := range runs {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		rr := &runReader{f: f, r: bufio.NewReader(f)}
+		if err := rr.next(); err != nil {
+			f.Close()
+			return err
+		}
+		if rr.head == nil {
+			f.Close()
+			continue
+		}
+		readers = append(readers, rr)
+	}
+	heap.Init(&readers)
+	var cur *spillRecord
+	for readers.Len() > 0 {
+		rr := readers[0]
+		rec := rr.head
+		if err := rr.next(); err != nil {
+			return err
+		}
+		if rr.head == nil {
+			rr.close()
+			heap.Pop(&readers)
+		} else {
+			heap.Fix(&readers, 0)
+		}
+		if cur != nil && cur.sameKey(rec) {
+			for i, v := range rec.values {
+				cur.values[i] += v
+			}
+			continue
+		}
+		if cur != nil {
+			fn(cur)
+		}
+		cur = rec
+	}
+	if cur != nil {
+		fn(cur)
+	}
+	return nil
+}
+
+// calcuSpill merge the runs and the prefix maps into the largest prefixes,
+// and the histograms of them, the runs are removed
+func (c *Counter) calcuSpill(num int) {
+	spill := c.spill
+	defer func() {
+		for _, path := range spill.runs {
+			os.Remove(path)
+		}
+		c.spill = &prefixSpill{dir: spill.dir, maxPrefixes: spill.maxPrefixes}
+	}()
+	if spill.err != nil {
+		c.errors = append(c.errors, &decoder.ErrorEntry{Offset: -1,
+			Error: fmt.Sprintf("spill key prefixes: %v, the smallest prefixes are dropped from then on and the counts of the others are lower bounds", spill.err)})
+	}
+	if len(spill.runs) == 0 {
+		return
+	}
+	records := c.prefixRecords()
+	c.clearPrefixes()
+	whatIf := map[typeKey]uint64{}
+	var largest map[string]bool
+	err := mergeRuns(spill.runs, records, func(r *spillRecord) {
+		switch r.kind {
+		case spillPrefix:
+			p := &PrefixEntry{typeKey: typeKey{Type: r.a, Key: r.b}, Bytes: r.values[0], Num: r.values[1]}
+			heap.Push(c.largestKeyPrefixes, p)
+			whatIf[p.typeKey] = r.values[2]
+			if c.largestKeyPrefixes.Len() > num {
+				delete(whatIf, heap.Pop(c.largestKeyPrefixes).(*PrefixEntry).typeKey)
+			}
+			return
+		}
+		// the records of prefixes come first, the histograms are of the
+		// largest prefixes only
+		if largest == nil {
+			largest = map[string]bool{}
+			for _, p := range *c.largestKeyPrefixes {
+				largest[p.Key] = true
+			}
+		}
+		if !largest[r.a] {
+			return
+		}
+		switch r.kind {
+		case spillIdle:
+			key := levelKey{Prefix: r.a, Level: r.b}
+			c.keyPrefixIdleBytes[key], c.keyPrefixIdleNum[key] = r.values[0], r.values[1]
+		case spillTTL:
+			key := levelKey{Prefix: r.a, Level: r.b}
+			c.keyPrefixTTLBytes[key], c.keyPrefixTTLNum[key] = r.values[0], r.values[1]
+		case spillEncoding:
+			key := encodingKey{Prefix: r.a, Type: r.b, Encoding: r.c}
+			c.keyPrefixEncBytes[key], c.keyPrefixEncNum[key] = r.values[0], r.values[1]
+		}
+	})
+	if err != nil {
+		c.errors = append(c.errors, &decoder.ErrorEntry{Offset: -1,
+			Error: fmt.Sprintf("merge spilled key prefixes: %v", err)})
+	}
+	if c.keyPrefixWhatIf != nil {
+		c.keyPrefixWhatIf = whatIf
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xueqiu/rdr/decoder"
+)
+
+func TestPrefixCounting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	memory, sketch, disk := NewCounter(), NewCounter(), NewCounter()
+	assert.NoError(t, sketch.SetPrefixCounting(PrefixCountingSketch, 20, ""))
+	assert.NoError(t, disk.SetPrefixCounting(PrefixCountingDisk, 7, dir))
+	assert.Error(t, NewCounter().SetPrefixCounting("bloom", 10, ""))
+	for i := 0; i < 2000; i++ {
+		// a few large prefixes among many small ones
+		e := &decoder.Entry{Key: "big" + string(rune('a'+i%3)) + ":x", Bytes: 100, Type: "string", Encoding: "embstr", Idle: int64(i), Freq: -1}
+		if i%2 == 1 {
+			// the digits of keys are masked, the small prefixes are letters
+			small := strconv.FormatInt(int64(i), 26)
+			small = strings.Map(func(r rune) rune { return 'k' + r%26 }, small)
+			e = &decoder.Entry{Key: "small" + small + ":x", Bytes: 1, Type: "string", Encoding: "int", Idle: -1, Freq: -1}
+		}
+		for _, c := range []*Counter{memory, sketch, disk} {
+			c.count(e)
+		}
+	}
+	assert.NotEmpty(t, disk.spill.runs)
+	for _, c := range []*Counter{memory, sketch, disk} {
+		c.calcu()
+	}
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 0)
+
+	exact := memory.GetLargestKeyPrefixes()
+	assert.Equal(t, exact, disk.GetLargestKeyPrefixes())
+	assert.Equal(t, memory.GetIdleLevelCount(), disk.GetIdleLevelCount())
+	assert.Equal(t, memory.GetKeyPrefixEncodingCount(), disk.GetKeyPrefixEncodingCount())
+
+	bytes := map[typeKey]uint64{}
+	for _, p := range exact {
+		bytes[p.typeKey] = p.Bytes
+	}
+	largest := sketch.GetLargestKeyPrefixes()
+	assert.Len(t, largest, 20)
+	for _, p := range largest {
+		assert.True(t, p.Bytes-p.BytesError <= bytes[p.typeKey] && bytes[p.typeKey] <= p.Bytes, p.Key)
+	}
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "big", largest[i].Key[:3])
+	}
+}
+
+func TestPrefixCountingBounds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	memory, sketch, disk := NewCounter(), NewCounter(), NewCounter()
+	sketch.SetPerDB(true)
+	assert.NoError(t, sketch.SetPrefixCounting(PrefixCountingSketch, 10, ""))
+	assert.NoError(t, disk.SetPrefixCounting(PrefixCountingDisk, 40, dir))
+	for i := 0; i < 3000; i++ {
+		e := &decoder.Entry{Key: "big" + string(rune('a'+i%3)) + ":x", Bytes: 100, Type: "string", Encoding: "embstr", Idle: int64(i), Freq: -1, DB: i % 4}
+		if i%2 == 1 {
+			small := strings.Map(func(r rune) rune { return 'k' + r%26 }, strconv.FormatInt(int64(i), 26))
+			e = &decoder.Entry{Key: "small" + small + ":x", Bytes: 1, Type: "string", Encoding: "int", Idle: int64(i), Freq: -1, DB: i % 4}
+		}
+		if i == 1500 {
+			// runs can not be written from then on
+			disk.spill.dir = dir + "/missing"
+		}
+		for _, c := range []*Counter{memory, sketch, disk} {
+			c.count(e)
+		}
+		assert.True(t, disk.prefixCounts() < 40)
+	}
+	// the databases share one sketch
+	assert.Len(t, sketch.sketch.dbs.items, 10)
+	for _, item := range sketch.sketch.s.items {
+		assert.True(t, len(item.idle) <= len(sketch.idleLevels)+1)
+	}
+	assert.NotEmpty(t, disk.spill.runs)
+	assert.Error(t, disk.spill.err)
+	for _, c := range []*Counter{memory, sketch, disk} {
+		c.calcu()
+	}
+
+	dbPrefixes := 0
+	for _, db := range sketch.GetDBs() {
+		dbPrefixes += len(sketch.GetDBCounter(db).GetLargestKeyPrefixes())
+	}
+	assert.Equal(t, 10, dbPrefixes)
+
+	// the counts spilled before the error are merged with those in memory
+	exact := map[typeKey]uint64{}
+	for _, p := range memory.GetLargestKeyPrefixes() {
+		exact[p.typeKey] = p.Bytes
+	}
+	largest := disk.GetLargestKeyPrefixes()
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, exact[largest[i].typeKey], largest[i].Bytes, largest[i].Key)
+	}
+	if assert.Len(t, disk.GetErrors(), 1) {
+		assert.Contains(t, disk.GetErrors()[0].Error, "lower bounds")
+	}
+}
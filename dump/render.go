@@ -16,6 +16,7 @@ package dump
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -42,36 +43,24 @@ func rdbReveal(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	}
 	counter := c.(*Counter)
 
-	data["CurrentInstance"] = path
-	data["LargestKeys"] = counter.GetLargestEntries(100)
-
-	largestKeyPrefixesByType := map[string][]*PrefixEntry{}
-	for _, entry := range counter.GetLargestKeyPrefixes() {
-		// mem use less than 1M, and list is long enough, not necessary to add
-		if entry.Bytes < 1000*1000 && len(largestKeyPrefixesByType[entry.Type]) > 50 {
-			continue
-		}
-		largestKeyPrefixesByType[entry.Type] = append(largestKeyPrefixesByType[entry.Type], entry)
-	}
-	data["LargestKeyPrefixes"] = largestKeyPrefixesByType
-
-	data["TypeBytes"] = counter.typeBytes
-	data["TypeNum"] = counter.typeNum
-	totleNum := uint64(0)
-	for _, v := range counter.typeNum {
-		totleNum += v
-	}
-	totleBytes := uint64(0)
-	for _, v := range counter.typeBytes {
-		totleBytes += v
+	for key, val := range getData(path, counter) {
+		data[key] = val
 	}
-	data["TotleNum"] = totleNum
-	data["TotleBytes"] = totleBytes
 
-	lenLevelCount := map[string][]*PrefixEntry{}
-	for _, entry := range counter.GetLenLevelCount() {
-		lenLevelCount[entry.Type] = append(lenLevelCount[entry.Type], entry)
+	// narrow the statistics down to one database, keep the list of all databases,
+	// the metadata and the decoding errors
+	data["CurrentDB"] = -1
+	if db, err := strconv.Atoi(r.URL.Query().Get("db")); err == nil {
+		if dbCounter := counter.GetDBCounter(db); dbCounter != nil && !dbCounter.TotalsOnly() {
+			for key, val := range getData(path, dbCounter) {
+				switch key {
+				case "DBs", "Metadata", "MemoryModel", "Errors", "ErrorNum":
+				default:
+					data[key] = val
+				}
+			}
+			data["CurrentDB"] = db
+		}
 	}
-	data["LenLevelCount"] = lenLevelCount
 	ServeHTML(w, "base.html", "revel.html", data)
 }
@@ -0,0 +1,291 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/xueqiu/rdr/decoder"
+	"gopkg.in/yaml.v2"
+)
+
+// Unclassified is the group and the team of keys no rule matches
+const Unclassified = "unclassified"
+
+// KeyRule map the keys matching a glob pattern or a regex to a group and
+// the team owning it
+type KeyRule struct {
+	Pattern string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Regex   string `json:"regex,omitempty" yaml:"regex,omitempty"`
+	Group   string `json:"group" yaml:"group"`
+	Team    string `json:"team,omitempty" yaml:"team,omitempty"`
+	re      *regexp.Regexp
+}
+
+// KeyRules classify keys by the first rule they match
+type KeyRules struct {
+	Rules []*KeyRule `json:"rules" yaml:"rules"`
+}
+
+// LoadKeyRules read the rules of a JSON or YAML file, or of a text file of
+// lines as
+//
+//	user:*:feed -> feed-service (team-timeline)
+//	/^session:[0-9a-f-]+$/ -> sessions (team-auth)
+//
+// where a pattern between slashes is a regex, the team is optional, and
+// empty lines and lines starting with # are ignored
+func LoadKeyRules(path string) (*KeyRules, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules := &KeyRules{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(content, rules)
+	case ".json":
+		dec := json.NewDecoder(strings.NewReader(string(content)))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(rules)
+	default:
+		rules.Rules, err = parseRuleLines(string(content))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules %s: %v", path, err)
+	}
+	for i, rule := range rules.Rules {
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("invalid rule %d of %s: %v", i+1, path, err)
+		}
+	}
+	return rules, nil
+}
+
+// ruleLine is PATTERN -> GROUP (TEAM)
+var ruleLine = regexp.MustCompile(`^(.+?)\s*->\s*([^\s()]+)\s*(?:\(([^()]*)\))?$`)
+
+func parseRuleLines(content string) ([]*KeyRule, error) {
+	rules := []*KeyRule{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := ruleLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("line %d is not PATTERN -> GROUP (TEAM): %s", n, line)
+		}
+		rule := &KeyRule{Group: m[2], Team: strings.TrimSpace(m[3])}
+		if p := m[1]; len(p) > 1 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+			rule.Regex = p[1 : len(p)-1]
+		} else {
+			rule.Pattern = p
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+func (r *KeyRule) compile() error {
+	if r.Group == "" {
+		return fmt.Errorf("no group")
+	}
+	expr := r.Regex
+	switch {
+	case r.Pattern != "" && r.Regex != "":
+		return fmt.Errorf("both pattern %q and regex %q", r.Pattern, r.Regex)
+	case r.Pattern != "":
+		expr = globToRegex(r.Pattern)
+	case r.Regex == "":
+		return fmt.Errorf("no pattern or regex")
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	r.re = re
+	return nil
+}
+
+// globToRegex convert a glob pattern of redis KEYS, where * and ? match any
+// characters and [...] a class of characters, to an anchored regex
+func globToRegex(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString("(?s:.*)")
+		case '?':
+			b.WriteString("(?s:.)")
+		case '[':
+			if j := strings.IndexByte(pattern[i+1:], ']'); j > 0 {
+				class := pattern[i+1 : i+1+j]
+				if class[0] == '^' {
+					class = "^" + regexp.QuoteMeta(class[1:])
+				} else {
+					class = regexp.QuoteMeta(class)
+				}
+				// ranges such as a-z are kept
+				b.WriteString("[" + class + "]")
+				i += j + 1
+				continue
+			}
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// Match return the group and the team of key, Unclassified if no rule
+// matches it, and the team is Unclassified if the rule has none
+func (rs *KeyRules) Match(key string) (group, team string) {
+	for _, rule := range rs.Rules {
+		if rule.re.MatchString(key) {
+			if rule.Team == "" {
+				return rule.Group, Unclassified
+			}
+			return rule.Group, rule.Team
+		}
+	}
+	return Unclassified, Unclassified
+}
+
+// GroupEntry record the keys of a group of the rules, or of a team if Group
+// is empty
+type GroupEntry struct {
+	Group       string
+	Team        string
+	Bytes       uint64
+	Num         uint64
+	LargestKeys []*decoder.Entry
+}
+
+type groupKey struct {
+	Group string
+	Team  string
+}
+
+// groupCounter count the keys of a group or a team
+type groupCounter struct {
+	entry   *GroupEntry
+	largest *entryHeap
+}
+
+func (g *groupCounter) count(e *decoder.Entry, num int) {
+	g.entry.Bytes += e.Bytes
+	g.entry.Num++
+	heap.Push(g.largest, e)
+	if g.largest.Len() > num {
+		heap.Pop(g.largest)
+	}
+}
+
+// groupCounters count the keys by group and by team
+type groupCounters struct {
+	rules  *KeyRules
+	groups map[groupKey]*groupCounter
+	teams  map[string]*groupCounter
+	// number of the largest keys of each group and team
+	largestNum int
+}
+
+func newGroupCounters(rules *KeyRules) *groupCounters {
+	return &groupCounters{
+		rules:      rules,
+		groups:     map[groupKey]*groupCounter{},
+		teams:      map[string]*groupCounter{},
+		largestNum: 10,
+	}
+}
+
+func (gc *groupCounters) count(e *decoder.Entry) {
+	group, team := gc.rules.Match(e.Key)
+	key := groupKey{Group: group, Team: team}
+	g, ok := gc.groups[key]
+	if !ok {
+		g = &groupCounter{entry: &GroupEntry{Group: group, Team: team}, largest: &entryHeap{}}
+		gc.groups[key] = g
+	}
+	g.count(e, gc.largestNum)
+	t, ok := gc.teams[team]
+	if !ok {
+		t = &groupCounter{entry: &GroupEntry{Team: team}, largest: &entryHeap{}}
+		gc.teams[team] = t
+	}
+	t.count(e, gc.largestNum)
+}
+
+// groupEntries get the entries of counters sorted by bytes
+func groupEntries(counters []*groupCounter) []*GroupEntry {
+	res := []*GroupEntry{}
+	for _, g := range counters {
+		largest := append(entryHeap{}, *g.largest...)
+		sort.Sort(sort.Reverse(largest))
+		g.entry.LargestKeys = largest
+		res = append(res, g.entry)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		if a.Team != b.Team {
+			return a.Team < b.Team
+		}
+		return a.Group < b.Group
+	})
+	return res
+}
+
+// GetGroups return the keys by group and by team of the rules, sorted by
+// bytes. ok is false without rules.
+func (c *Counter) GetGroups() (groups, teams []*GroupEntry, ok bool) {
+	if c.groups == nil {
+		return nil, nil, false
+	}
+	gs := []*groupCounter{}
+	for _, g := range c.groups.groups {
+		gs = append(gs, g)
+	}
+	ts := []*groupCounter{}
+	for _, t := range c.groups.teams {
+		ts = append(ts, t)
+	}
+	return groupEntries(gs), groupEntries(ts), true
+}
+
+// SetKeyRules count the keys by group and by team of rules
+func (c *Counter) SetKeyRules(rules *KeyRules) {
+	c.groups = newGroupCounters(rules)
+}
@@ -0,0 +1,84 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+	"github.com/xueqiu/rdr/decoder"
+)
+
+func TestKeyRules(t *testing.T) {
+	f, err := ioutil.TempFile("", "rules")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`# key rules
+user:*:feed -> feed (team-timeline)
+/^session:[0-9a-f-]+$/ -> sessions (team-auth)
+cache:[ab]? -> cache
+`)
+	f.Close()
+
+	rules, err := LoadKeyRules(f.Name())
+	if !assert.NoError(t, err) || !assert.Len(t, rules.Rules, 3) {
+		return
+	}
+	for key, expected := range map[string][2]string{
+		"user:1:feed":      {"feed", "team-timeline"},
+		"user:1:feed:x":    {Unclassified, Unclassified},
+		"session:3fa85f64": {"sessions", "team-auth"},
+		"cache:a1":         {"cache", Unclassified},
+		"cache:c1":         {Unclassified, Unclassified},
+	} {
+		group, team := rules.Match(key)
+		assert.Equal(t, expected, [2]string{group, team}, key)
+	}
+
+	c := NewCounter()
+	c.SetKeyRules(rules)
+	c.count(&decoder.Entry{Key: "user:1:feed", Bytes: 10, Type: "list", Idle: -1, Freq: -1})
+	c.count(&decoder.Entry{Key: "user:2:feed", Bytes: 30, Type: "list", Idle: -1, Freq: -1})
+	c.count(&decoder.Entry{Key: "session:ab", Bytes: 25, Type: "string", Idle: -1, Freq: -1})
+	c.count(&decoder.Entry{Key: "other", Bytes: 5, Type: "string", Idle: -1, Freq: -1})
+	groups, teams, ok := c.GetGroups()
+	if assert.True(t, ok) && assert.Len(t, groups, 3) && assert.Len(t, teams, 3) {
+		assert.Equal(t, "feed", groups[0].Group)
+		assert.Equal(t, uint64(40), groups[0].Bytes)
+		assert.Equal(t, uint64(2), groups[0].Num)
+		assert.Equal(t, "user:2:feed", groups[0].LargestKeys[0].Key)
+		assert.Equal(t, "team-timeline", teams[0].Team)
+		assert.Equal(t, Unclassified, teams[2].Team)
+	}
+
+	_, _, ok = NewCounter().GetGroups()
+	assert.False(t, ok)
+}
+
+func TestRulesFlag(t *testing.T) {
+	for _, action := range []func(*cli.Context) error{ToCliWriter, Advise} {
+		out, err := runCommand(action, "--rules", "missing.rules", "dump.rdb")
+		if exit, ok := err.(cli.ExitCoder); assert.True(t, ok, "%v", err) {
+			assert.Equal(t, 1, exit.ExitCode())
+			assert.Contains(t, exit.Error(), "configure err: rules: ")
+		}
+		assert.Empty(t, out)
+	}
+}
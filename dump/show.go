@@ -21,11 +21,18 @@ var counters = NewSafeMap()
 
 func listPathFiles(pathname string) []string {
 	var filenames []string
+	if pathname == StdinPath {
+		return append(filenames, pathname)
+	}
 	fi, err := os.Lstat(pathname) // For read access.
 	if err != nil {
 		return filenames
 	}
 	if fi.IsDir() {
+		// an append only file of redis 7
+		if manifests, _ := filepath.Glob(path.Join(pathname, "*.manifest")); len(manifests) > 0 {
+			return append(filenames, pathname)
+		}
 		files, err := ioutil.ReadDir(pathname)
 		if err != nil {
 			log.Fatal(err)
@@ -41,11 +48,17 @@ func listPathFiles(pathname string) []string {
 }
 
 // Show parse rdbfile(s) and show statistical information by html
-func Show(c *cli.Context) {
+func Show(c *cli.Context) error {
 	if c.NArg() < 1 {
 		fmt.Fprintln(c.App.ErrWriter, "show requires at least 1 argument")
 		cli.ShowCommandHelp(c, "show")
-		return
+		return nil
+	}
+	// the counter of the first rdbfile checks the flags before the server
+	// starts
+	first, err := newCounter(c)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("configure err: %v", err), 1)
 	}
 
 	// parse rdbfile
@@ -56,14 +69,22 @@ func Show(c *cli.Context) {
 		for {
 			for _, pathname := range c.Args() {
 				for _, v := range listPathFiles(pathname) {
-					filename := filepath.Base(v)
+					filename := instanceName(v)
 
 					if !counters.Check(filename) {
 						decoder := decoder.NewDecoder()
 						fmt.Fprintf(c.App.Writer, "start to parse %v \n", filename)
+						counter := first
+						first = nil
+						if counter == nil {
+							var err error
+							if counter, err = newCounter(c); err != nil {
+								fmt.Fprintf(c.App.ErrWriter, "configure err: %v\n", err)
+								continue
+							}
+						}
 						go Decode(c, decoder, v)
-						counter := NewCounter()
-						counter.Count(decoder.Entries)
+						counter.CountDecoder(decoder)
 						counters.Set(filename, counter)
 						fmt.Fprintf(c.App.Writer, "parse %v  done\n", filename)
 
@@ -94,4 +115,5 @@ func Show(c *cli.Context) {
 	if listenErr != nil {
 		fmt.Fprintf(c.App.ErrWriter, "Listen port err: %v\n", listenErr)
 	}
+	return nil
 }
@@ -40,6 +40,12 @@ func InitHTMLTmpl() {
 	tplFuncMap["clearFirst"] = func() bool { isFirst = true; return isFirst }
 	tplFuncMap["hash"] = func(str string) string { return fmt.Sprintf("%x", md5.Sum([]byte(str))) }
 	tplFuncMap["humanizeBytes"] = humanize.Bytes
+	tplFuncMap["humanizeSignedBytes"] = func(i int64) string {
+		if i < 0 {
+			return "-" + humanize.Bytes(uint64(-i))
+		}
+		return humanize.Bytes(uint64(i))
+	}
 	tplFuncMap["humanizeComma"] = func(i uint64) string { return humanize.Comma(int64(i)) }
 
 	// init views html template
@@ -0,0 +1,61 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"fmt"
+
+	"github.com/dongmx/rdb"
+	"github.com/dongmx/rdb/nopdecoder"
+	"github.com/urfave/cli"
+)
+
+// Verify check the version header and the CRC64 checksum of rdbfiles
+func Verify(c *cli.Context) error {
+	if c.NArg() < 1 {
+		fmt.Fprintln(c.App.ErrWriter, "verify requires at least 1 argument")
+		cli.ShowCommandHelp(c, "verify")
+		return nil
+	}
+	failed := 0
+	for _, path := range c.Args() {
+		if err := verifyFile(c, path); err != nil {
+			fmt.Fprintf(c.App.Writer, "%s: FAILED %v\n", path, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return cli.NewExitError(fmt.Sprintf("%d of %d rdbfiles failed verification", failed, c.NArg()), 1)
+	}
+	return nil
+}
+
+func verifyFile(c *cli.Context, path string) error {
+	f, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ver, checksum, err := rdb.Verify(f, nopdecoder.NopDecoder{})
+	if err != nil {
+		return err
+	}
+	if checksum == 0 {
+		fmt.Fprintf(c.App.Writer, "%s: OK rdb version %d, no checksum\n", path, ver)
+	} else {
+		fmt.Fprintf(c.App.Writer, "%s: OK rdb version %d, checksum %016x\n", path, ver, checksum)
+	}
+	return nil
+}
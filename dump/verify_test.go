@@ -0,0 +1,100 @@
+// Copyright 2017 XUEQIU.COM
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dongmx/rdb"
+	"github.com/dongmx/rdb/nopdecoder"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	good, offset := testRDB(false)
+	ver, checksum, err := rdb.Verify(bytes.NewReader(good), nopdecoder.NopDecoder{})
+	assert.NoError(t, err)
+	assert.Equal(t, 9, ver)
+	assert.Equal(t, binary.LittleEndian.Uint64(good[len(good)-8:]), checksum)
+
+	// the value of second changed after the checksum was computed
+	mismatch := append([]byte{}, good...)
+	mismatch[offset+9] = '9'
+	_, _, err = rdb.Verify(bytes.NewReader(mismatch), nopdecoder.NopDecoder{})
+	if derr, ok := err.(*rdb.DecodeError); assert.True(t, ok, "%v", err) {
+		assert.Contains(t, derr.Err.Error(), "checksum mismatch")
+		assert.Equal(t, int64(len(good)-8), derr.Offset)
+		assert.Equal(t, "third", derr.LastKey)
+	}
+
+	// truncated in the value of second
+	truncated := good[:offset+8]
+	_, _, err = rdb.Verify(bytes.NewReader(truncated), nopdecoder.NopDecoder{})
+	if derr, ok := err.(*rdb.DecodeError); assert.True(t, ok, "%v", err) {
+		assert.Equal(t, int64(len(truncated)), derr.Offset)
+		assert.Equal(t, "second", derr.Key)
+		assert.Equal(t, "first", derr.LastKey)
+	}
+
+	// no checksum before version 5
+	old := append([]byte("REDIS0004\xfe\x00"), rdbString("first", "1")...)
+	old = append(old, 0xff)
+	ver, checksum, err = rdb.Verify(bytes.NewReader(old), nopdecoder.NopDecoder{})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, ver)
+	assert.Zero(t, checksum)
+}
+
+func TestVerifyCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verify")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	good, _ := testRDB(false)
+	bad := good[:len(good)-1]
+	goodPath, badPath := filepath.Join(dir, "good.rdb"), filepath.Join(dir, "bad.rdb")
+	assert.NoError(t, ioutil.WriteFile(goodPath, good, 0644))
+	assert.NoError(t, ioutil.WriteFile(badPath, bad, 0644))
+
+	verify := func(paths ...string) (string, error) {
+		app := cli.NewApp()
+		out := &bytes.Buffer{}
+		app.Writer, app.ErrWriter = out, out
+		set := flag.NewFlagSet("verify", flag.ContinueOnError)
+		set.Parse(paths)
+		err := Verify(cli.NewContext(app, set, nil))
+		return out.String(), err
+	}
+
+	out, err := verify(goodPath)
+	assert.NoError(t, err)
+	assert.Contains(t, out, goodPath+": OK rdb version 9, checksum")
+
+	out, err = verify(goodPath, badPath)
+	if exit, ok := err.(cli.ExitCoder); assert.True(t, ok, "%v", err) {
+		assert.Equal(t, 1, exit.ExitCode())
+		assert.Equal(t, "1 of 2 rdbfiles failed verification", exit.Error())
+	}
+	assert.Contains(t, out, goodPath+": OK")
+	assert.Contains(t, out, badPath+": FAILED")
+}
@@ -7,7 +7,10 @@ require (
 	github.com/dustin/go-humanize v1.0.0
 	github.com/elazarl/go-bindata-assetfs v1.0.0
 	github.com/julienschmidt/httprouter v1.3.0
+	github.com/klauspost/compress v1.11.13
+	github.com/pierrec/lz4 v2.6.1+incompatible
 	github.com/pkg/errors v0.8.1 // indirect
 	github.com/stretchr/testify v1.4.0
 	github.com/urfave/cli v1.22.1
+	gopkg.in/yaml.v2 v2.2.2
 )
@@ -45,6 +45,105 @@ func keys(c *cli.Context) {
 	}
 }
 
+// decodeFlags are options of the rdbfile decoding, shared by all commands
+var decodeFlags = []cli.Flag{
+	cli.IntSliceFlag{
+		Name:  "db",
+		Usage: "Only decode keys of database `N`, can be repeated",
+	},
+	cli.BoolFlag{
+		Name:  "verify",
+		Usage: "Check the CRC64 checksum of rdbfiles",
+	},
+	cli.BoolFlag{
+		Name:  "tolerant",
+		Usage: "Skip keys which fail to decode and go on, the errors are reported with the statistics",
+	},
+}
+
+// counterFlags are options of the statistics, shared by `dump` and `show`
+var counterFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "memory-model",
+		Usage: "Estimate memory use with a built-in `MODEL` such as redis6-jemalloc or redis7-libc, or a JSON or YAML model file",
+	},
+	cli.StringFlag{
+		Name:  "maxmemory-policy",
+		Usage: "maxmemory-policy of redis, integer values are not shared with a LRU or LFU `POLICY`",
+	},
+	cli.IntFlag{
+		Name:  "list-compress-depth",
+		Usage: "list-compress-depth of redis, the quicklist nodes saved uncompressed but `DEPTH` nodes at each end are counted LZF compressed",
+	},
+	cli.StringFlag{
+		Name:  "redis-conf",
+		Usage: "Estimate memory use once more with the encoding thresholds of redis.conf `FILE`, and report the difference per key prefix",
+	},
+	cli.StringFlag{
+		Name:  "separators",
+		Value: ":;,_- ",
+		Usage: "Split keys into prefixes at any of `CHARS`",
+	},
+	cli.StringFlag{
+		Name:  "segment-chars",
+		Usage: "`CHARS` of the separators which are part of the segments of keys, such as _ of user_id",
+	},
+	cli.StringSliceFlag{
+		Name:  "normalize",
+		Usage: "Replace identifiers of keys with a placeholder by `NORMALIZER`, one of email, uuid, ip, ts, hex and b64, or NAME=REGEX, can be repeated, all built-in ones by default and none with none",
+	},
+	cli.IntFlag{
+		Name:  "max-prefix-depth",
+		Usage: "Count the first `N` prefixes of a key only, all if 0",
+	},
+	cli.StringFlag{
+		Name:  "group-by",
+		Value: "prefix",
+		Usage: "Group keys by `MODE`, prefix splits them at the separators, pattern learns templates such as userfeed{num} from the shape of the keys",
+	},
+	cli.IntFlag{
+		Name:  "max-patterns",
+		Value: 1000,
+		Usage: "Keep at most `N` templates of --group-by pattern, the keys which fit none are counted as {other}",
+	},
+	cli.Float64Flag{
+		Name:  "pattern-similarity",
+		Value: 0.6,
+		Usage: "A key fits a template of --group-by pattern if at least `RATIO` of their words are equal",
+	},
+	cli.StringFlag{
+		Name:  "prefix-counting",
+		Value: "memory",
+		Usage: "Count key prefixes by `MODE`, memory keeps all of them, sketch keeps the largest --max-prefixes only with error bounds, disk spills them to --spill-dir",
+	},
+	cli.IntFlag{
+		Name:  "max-prefixes",
+		Value: 1000000,
+		Usage: "Keep at most `N` key prefixes and their histograms in memory with --prefix-counting sketch or disk, and as many for all databases with --per-db",
+	},
+	cli.StringFlag{
+		Name:  "spill-dir",
+		Usage: "Spill key prefixes to `DIR` with --prefix-counting disk, the temporary directory by default",
+	},
+	cli.StringFlag{
+		Name:  "rules",
+		Usage: "Count keys by group and owning team of the rules `FILE`, lines of PATTERN -> GROUP (TEAM), or JSON or YAML",
+	},
+	cli.BoolFlag{
+		Name:  "per-db",
+		Usage: "Keep the largest keys, prefixes and other statistics of each database, which takes about as much memory again, the totals by type of each database are kept anyway",
+	},
+	cli.BoolFlag{
+		Name:  "calibrate",
+		Usage: "Scale the estimates of keys to add up to the used-mem of the rdbfile",
+	},
+	cli.IntFlag{
+		Name:  "cold-days",
+		Value: 30,
+		Usage: "Keys idle longer than `DAYS` are counted as cold",
+	},
+}
+
 func main() {
 	app := cli.NewApp()
 	app.Name = "rdr"
@@ -57,25 +156,59 @@ func main() {
 			Name:      "dump",
 			Usage:     "dump statistical information of rdbfile to STDOUT",
 			ArgsUsage: "FILE1 [FILE2] [FILE3]...",
+			Flags:     append(decodeFlags, counterFlags...),
 			Action:    dump.ToCliWriter,
 		},
 		cli.Command{
 			Name:      "show",
 			Usage:     "show statistical information of rdbfile by webpage",
 			ArgsUsage: "DIR1 [DIR2] [DIR3] or FILE1 [FILE2] [FILE3]...",
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				cli.UintFlag{
 					Name:  "port, p",
 					Value: 8080,
 					Usage: "Port for rdr to listen",
 				},
-			},
+			}, append(decodeFlags, counterFlags...)...),
 			Action: dump.Show,
 		},
+		cli.Command{
+			Name:      "advise",
+			Usage:     "print memory optimizations of rdbfile ranked by estimated savings",
+			ArgsUsage: "FILE1 [FILE2] [FILE3]...",
+			Flags: append([]cli.Flag{
+				cli.IntFlag{
+					Name:  "top",
+					Value: 20,
+					Usage: "Print the `N` pieces of advice with the largest savings, 0 for all",
+				},
+			}, append(decodeFlags, counterFlags...)...),
+			Action: dump.Advise,
+		},
+		cli.Command{
+			Name:      "verify",
+			Usage:     "check the version header and CRC64 checksum of rdbfile",
+			ArgsUsage: "FILE1 [FILE2] [FILE3]...",
+			Action:    dump.Verify,
+		},
+		cli.Command{
+			Name:      "memory-model",
+			Usage:     "print the sizes of a built-in memory model as JSON, to be edited into a model file",
+			ArgsUsage: "MODEL",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "bits",
+					Value: 64,
+					Usage: "Word size of redis, 32 or 64",
+				},
+			},
+			Action: dump.PrintMemoryModel,
+		},
 		cli.Command{
 			Name:      "keys",
 			Usage:     "get all keys from rdbfile",
 			ArgsUsage: "FILE1 [FILE2] [FILE3]...",
+			Flags:     decodeFlags,
 			Action:    keys,
 		},
 	}
@@ -113,7 +113,7 @@ func baseHtml() (*asset, error) {
 	return a, nil
 }
 
-var _chartjsHtml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xec\x58\xdf\x8f\x9b\xb8\x16\x7e\xef\x5f\x71\xc4\xad\x44\xa2\x66\x68\xe8\xed\x13\x6d\x2a\xcd\x4c\x6f\x75\xbb\x5b\xb5\x95\x5a\xad\xb6\x8a\xf2\xe0\x84\x13\x62\xd5\xd8\xc8\x36\x93\xd0\x88\xff\x7d\x65\x0c\x04\xc2\x8f\x61\x57\xd5\xbe\xec\xfa\x61\x06\xf0\x77\x3e\x7f\x3e\x3e\xfe\x30\x79\xad\x76\x92\x26\xfa\xcd\x13\x00\x80\xfb\x03\x91\xda\x4b\x58\x1a\x51\xfe\x05\xe5\x03\xdd\xa1\x27\x31\xa2\x4a\xa3\x9c\x9d\x0b\x88\x69\x64\xaf\x51\xbe\x95\xe4\x18\xc0\x3e\xe5\x3b\x4d\x05\x9f\xed\x4c\xe8\x1c\x2e\x20\xd3\xe8\x1e\x6c\x87\xb7\x13\x7c\x4f\x23\x4f\x24\x06\xac\x3c\x64\x18\x23\xd7\xca\xdb\x21\xd7\x28\xaf\xe3\x4c\x7b\x20\x12\x0e\xc8\x12\x94\x0a\x56\xa5\xb2\xf2\xfe\x55\x2f\xd8\x52\xfd\x0e\xab\x7a\x4c\xf3\xf7\x56\x22\xf1\x18\xee\x35\x3c\x83\xeb\xc7\x92\x46\x07\x3d\x87\xe7\xf0\x62\x8c\xf1\x5b\x1f\xa3\x16\x49\x0f\xe1\x56\x68\x2d\xe2\x92\xb1\x9f\x52\x9f\x60\xd5\x8c\xf3\x76\xfa\xd4\x1d\x7d\xa7\x4f\x9e\x22\x0f\x38\x9b\xf7\x2b\xdb\x0b\xae\xbf\xd0\x1f\x08\xab\x2a\x47\x5e\x84\xfa\x37\xc2\x52\xfc\x24\xdf\xe2\x9e\xa4\x4c\x4f\xca\xbc\x57\x31\x2d\xca\x14\x87\x36\x58\x79\x11\x13\x5b\xc2\xaa\xfb\x77\x25\x6c\x4c\x8f\xce\xd8\xcf\x12\x64\xa8\xa6\x28\x32\xb8\x11\x49\xef\x48\x4c\x59\xf6\x73\x34\x59\xae\x09\xa2\x2c\x70\x44\x55\x43\x8f\x9d\xac\xa4\x3c\x9a\x5d\x16\xa2\x91\x81\xfd\x18\x9f\x29\x92\x92\xcf\xfc\x1b\x00\x50\xc6\x7e\xe2\xca\xdc\x0b\x26\xe4\x84\x24\x14\xb8\x01\xcd\x1a\x4f\xfa\x96\xd1\x88\xc3\x0a\x5c\xcb\xed\x0e\x23\xef\x88\x42\x46\xb9\xd1\xef\xc6\x34\x0c\x19\x0e\x80\xcd\x4c\xbf\xe2\x69\xe2\x64\x0c\xf5\xa2\xf2\x8c\xea\xe2\xdb\x80\x62\x89\x4a\x0b\xd9\xd9\x8d\x79\x7d\x97\x2f\x8a\xcb\x7c\x6e\x77\xbd\x59\xe9\x84\xe2\x7d\x21\x01\x56\x0d\x77\xd3\x59\x82\x01\xb8\xa1\x48\xa3\x03\x4f\xb5\xbb\xa8\x7b\x42\xa2\x49\x70\xe5\x83\xe6\x99\x42\xad\x02\x58\x77\x0d\xd2\x06\xac\x37\x8b\x4e\xcf\x96\xec\xbe\x47\x52\xa4\x3c\x2c\x96\x21\x80\x75\x07\x62\xda\x91\xf2\x50\x1c\xad\x0d\x15\x40\xe5\x49\x0c\xbb\x74\x03\x58\x21\x09\x8f\x70\x32\x3c\x43\xc6\xc4\x71\x32\x3c\x92\x88\x7c\x32\x7a\xcb\xd2\x1e\x25\x57\xa9\xc9\xaf\xee\x19\xd9\x22\x33\xb9\xdd\x5c\xaf\xa3\x69\x65\xe1\x5c\x2f\x89\xfe\x8c\xd2\x14\x0b\x89\x30\x80\x97\xcb\x36\xa3\x44\x95\x08\xae\xe8\x03\x06\xa0\xe5\xb5\x24\x86\x11\xf2\xf0\x9a\xd0\xb4\x90\xaa\x84\x91\x2c\x80\x3d\x61\x0a\xdb\xa2\xdb\x1c\x9a\x6a\x86\xa3\x14\xdd\x71\x4d\xab\xf7\x6e\x00\xce\x7f\x96\xcb\xa5\xd3\x0f\x31\x06\x14\x80\xff\xdf\x6e\xaf\xd9\x2f\x01\xb8\xdf\x31\x03\x9e\xc6\x5b\x94\xb0\xcd\x8a\x6a\x56\xee\x98\xde\x6a\xdb\xf5\x49\xb6\x7b\xae\xaf\xa7\x31\xa0\xdb\x5f\x03\x8d\xf9\xb8\x66\x3e\x23\x30\xeb\x9f\x01\x38\xee\xff\x91\x3d\xa0\xa6\x3b\x02\x1f\x31\x45\x77\x01\x97\x27\xe6\xe6\x56\x52\xc2\xdc\x05\x28\xc2\xd5\x8d\x42\x49\xf7\x3d\x59\x6a\x67\xea\xc5\xcb\x11\x84\x71\xdd\x00\x5c\x2e\x64\x4c\x98\xdb\xc1\xe5\x43\x5e\x62\xad\xc4\x5a\x4d\x59\xeb\x82\x33\x41\x42\x63\xf4\xd5\x79\xab\x79\x64\x32\x76\x23\xc9\xf1\x73\xc3\x71\x7e\xf9\xf2\xe9\xa3\xa7\x8a\x37\x0b\xdd\x67\xb3\xda\x8c\x1a\x0e\xd6\x32\xa9\x65\x15\x93\x10\xa9\x70\xd6\x64\x1b\x0a\xf1\x27\x85\x5c\x46\xa8\xad\xb8\xa8\xe1\xc2\x80\x61\x05\x4e\xb7\xa2\x9c\xd1\xe8\x3e\x23\x37\x16\x7b\x3e\xa4\x31\xe1\xf4\x07\xde\x8b\x38\x26\xe0\x7d\x15\x9a\xe1\xc7\x34\xce\xf3\x2e\x9b\x3f\xa0\x25\xc6\x58\xc8\x0c\x52\x85\x61\x25\x06\x66\xdb\x4c\xe3\xbc\x4f\x93\x3f\x5d\xd3\x5d\xa6\x51\x95\x9a\x8a\xeb\x3c\xbf\x9c\x0f\xcf\xe7\xc2\x49\xe1\xa9\x19\x6f\x01\x4f\x79\x1a\x43\xb0\x02\xef\x6b\x96\x0c\x4d\x60\xe9\x19\xf7\xf7\xaa\xf7\xc3\x7a\xb9\xb1\x0f\x92\x54\x1d\x66\xe7\xb3\xa1\xc8\xf3\xbe\x55\xf0\xc7\x03\x29\x0f\xf1\x04\x4f\x8b\xa1\xad\xe6\x42\x54\x3f\x57\x29\xc2\xda\x68\x3d\xf4\x30\xde\x1f\xc5\x43\x23\x1f\xc8\xc3\x66\x82\xaa\xb2\xd3\x27\x53\xa6\xa1\xd8\xa5\x26\xdb\xe6\x18\xf3\x3f\x9b\xf8\xbb\xec\x7d\x38\x73\x8a\x17\xc2\x0d\x91\x48\x6e\x96\xce\xdc\x74\xdf\x0b\x6e\x96\x62\xe6\xbc\x08\x9d\x86\xa2\x72\x53\xc5\xd9\x67\x8a\x86\x91\xe3\xd1\x9e\x68\x66\xe5\x28\x8b\xc6\x24\x7b\xca\x5f\x9f\xfc\x89\x3a\xfc\xc9\x3a\xfc\x1e\x1d\x7e\x43\x87\x3f\x7f\xd5\x4e\xc8\x4e\xa4\xc5\xd1\xef\x9c\xb7\x52\xd7\x2e\x25\xe4\x5a\x52\x54\x45\x39\x7d\x40\xfe\x01\x1f\x90\xdd\x9b\xc0\x46\x51\x19\xb2\x0a\xb7\x82\xf5\xa6\x8f\xce\xf4\x67\x86\xa5\x62\x6c\xc4\x97\x4f\xca\x05\xed\xbe\x61\x03\x38\x9f\x2d\x81\xf7\x2b\x66\xf9\xd5\x0b\xc2\xec\x2e\xd5\x80\x94\xbb\xa3\x0d\xe2\x69\xdc\x80\xb4\xf7\x44\x6f\xe9\x54\xf7\x45\x92\xd6\x75\x95\x6d\x60\x55\xc9\x1d\xab\xb7\xbd\x90\x30\xd3\x59\x02\x94\x5b\x86\xeb\xef\xd3\x76\xca\xec\x20\x3a\x4b\x36\xaf\x3a\x28\x49\x8e\x77\x44\x0e\xd9\xf2\xb6\xea\x9a\x77\x23\xeb\xbe\xae\x35\xdf\x4d\x09\xeb\xda\xf3\x50\x58\x31\x5d\x6a\x26\x5b\xce\x69\xe8\x73\xdc\x56\x41\x9d\xc2\x35\xdd\x74\x8f\xcb\x17\xd9\x63\x4e\x63\x17\x92\xa7\x71\xcf\x81\xfb\x32\x83\xc7\x19\x8a\xea\x19\xe3\xe8\xf1\x28\xe7\x8d\x03\xcf\xec\x5c\xec\xf3\x09\x1a\xfe\x4c\x7c\xde\xbb\x26\x8f\x78\xd7\x96\xc8\xe5\x0d\x41\x49\x6e\x0c\xb9\xce\x92\x31\xdf\x80\xda\x3b\xd6\x4e\x9c\xdd\x11\xb9\x2c\x83\x36\x2d\x13\x29\x87\x5d\x34\xb2\x31\x50\x30\x8f\x18\xda\x96\x48\xff\xaf\x8a\x93\xc4\x1f\x11\xe7\x37\xc4\xf9\x0d\x92\xfa\x0c\xf4\xfa\x79\xf5\xfb\xd4\x93\xd6\x2f\x55\xad\x52\xef\xfb\xc2\xda\x12\xf9\xef\xc7\xd5\x10\xfc\x6f\xf8\xb8\x3a\xdd\x9e\xa8\x7a\xff\x36\x00\x67\x4b\xa3\x08\x25\xe8\x03\xe1\x57\xe7\xe9\xac\xc6\xd8\x03\xa0\xf3\x4f\xf8\x3e\x2b\x28\x16\x8f\x9d\xfd\xeb\xba\xff\x23\x00\x00\xff\xff\xcd\xc5\x01\x0b\xa0\x15\x00\x00")
+var _chartjsHtml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xed\x58\xdf\x6f\xdb\x36\x10\x7e\xef\x5f\x41\x78\x05\x2c\xaf\x8e\x6a\x75\x7d\x72\xd2\x02\x49\xda\x61\xdd\x82\xb4\x58\x83\x61\x45\x90\x07\xda\x3a\xcb\x44\x29\xca\x20\xa9\xc4\x5a\xe0\xff\x7d\x47\x51\x92\xa9\x9f\x51\x87\x02\x7b\xd8\xf4\x90\x58\xe4\xdd\xc7\xef\x8e\xc7\x8f\xa4\xce\xd4\x5a\xb2\x9d\x7e\xfb\x8c\xe0\x73\xb9\xa5\x52\xfb\x3b\x9e\x46\x4c\x7c\x06\x79\xcf\xd6\xe0\x4b\x88\x98\xd2\x20\xbd\xc7\xdc\xc4\x3c\x74\x83\xef\xef\x24\x7d\x58\x92\x4d\x2a\xd6\x9a\x25\xc2\x5b\x1b\xd7\x19\x39\x1a\x99\x87\x6d\x88\xed\xf0\xd7\x89\xd8\xb0\xc8\x4f\x76\xc6\x58\xf9\xc0\x21\x06\xa1\x95\xbf\xc6\xbf\x20\x9b\x7e\xe6\xb9\xa7\x92\x6c\x81\xef\x40\x2a\xf2\xa6\x60\x56\xbc\x9f\x76\x1a\x5b\xa8\x3f\xd1\xb8\x1c\xd3\xfc\x3d\x97\x40\x7d\x0e\x1b\x4d\x5e\x90\x66\xb3\x64\xd1\x16\x39\xbf\x24\xaf\x86\x10\xbf\x74\x21\xea\x64\xd7\x01\xb8\x4a\xb4\x4e\xe2\x02\xb1\x1b\x52\xef\x11\xce\xf1\xf3\xb1\xa5\x3d\x3a\x36\xfa\x8a\xde\x83\x37\xeb\x66\xb6\x49\x84\xfe\xcc\xfe\x02\xc4\x2a\x72\xe2\x47\xa0\xff\xa0\x3c\x85\x8f\xf2\x1d\x6c\x68\xca\xf5\xa8\xcc\xfb\x25\xd2\xbc\x48\x71\x68\x9d\x11\x8f\x27\x2b\xca\xcb\xf7\x9f\x0b\xb3\x21\x3e\x3a\xe3\xdf\x8b\x90\x81\x1a\xc3\xc8\xd8\x0d\x50\xfa\x99\xc6\x8c\x67\xdf\x87\x93\xc5\x1a\x41\xca\x1a\x0e\xb0\x72\xf8\xd8\x60\x25\x13\x91\x77\x9c\x08\x27\x03\x9b\x21\x3c\x53\x24\x05\x9e\xf9\xd7\x63\xc0\x38\xff\x8e\x33\x73\x99\xf0\x44\x8e\x48\x42\x6e\xd7\xc3\x59\xc3\x5e\x9f\x73\x16\x09\xa4\x34\xb5\xd8\xd3\x7e\xcb\x0b\xaa\x80\x33\x61\xf8\x4f\x63\x16\x86\x1c\xa6\xfd\x91\xde\xa0\xc3\xb8\x60\x0c\xf4\xbc\xd4\x8c\xf2\xc7\x97\x1e\xc6\x12\x94\x4e\x64\x6b\x35\x1e\xaa\xb7\xc3\x3c\xff\x79\x98\xd9\x55\x6f\x66\x7a\xc7\xe0\x32\xa7\x80\xc4\x8f\xea\xa6\xb3\x1d\x2c\xc9\x34\x4c\xd2\x68\x2b\x52\x3d\x9d\x57\x3d\x21\xd5\x74\xd9\xd0\x41\xd3\xa6\x40\xab\x25\xb9\x6d\x0b\xa4\x75\xb8\xbd\x9b\xb7\x7a\x56\x74\xfd\x35\x92\x49\x2a\xc2\x7c\x1a\xd0\xa8\x65\x62\x9e\x07\x26\xc2\xe4\xc1\xca\x50\x6e\xa8\x30\xd0\x70\x3e\xd6\x36\x91\x54\x44\x30\xda\x3c\x03\xce\x93\x87\xd1\xe6\x91\x04\x10\xa3\xad\x57\x58\xcd\x6d\xe3\x46\x6a\x0e\x8d\x77\x4e\x57\xc0\x4d\x6e\xef\x9a\xf3\x68\x9e\xa2\x70\x9a\x53\xa2\x3f\x81\x34\xc5\x42\x23\x9c\xc7\xd7\x8b\x3a\x22\xd6\xc9\x0e\x7d\xd8\x3d\xf6\x69\xd9\xa4\xc4\x21\x02\x11\x2e\x3b\xf6\xba\x90\xa9\x1d\xa7\x19\x6e\xa7\x94\x2b\xa8\x93\xae\x63\x68\xa6\x39\x0c\x42\xb4\xc7\x35\x4f\xb5\x76\x97\x64\xf2\xc3\x62\xb1\x98\x74\x9b\x18\x01\x5a\x92\xe0\xa7\x76\xaf\x59\x2f\x58\xb8\x5f\x21\x23\x22\x8d\x57\x20\xc9\x2a\xcb\xab\x59\x4d\x87\xf8\x96\xcb\xae\x8b\xb2\x5d\x73\x5d\x3d\xce\x80\xd3\xee\x1a\x70\xe2\x99\x9a\x78\x06\xcc\xac\x7e\x62\xdc\xd3\x5f\x80\xdf\x83\x66\x6b\x4a\xae\x21\x85\xe9\x9c\x1c\x5b\xcc\xcb\xb9\x64\x94\xe3\x0f\x45\x85\x3a\x51\x20\xd9\x66\xd2\x8f\x6a\x33\xf5\xea\xf5\x80\x85\x51\x5d\xa4\x27\x12\x19\x23\x6e\xcb\xee\xd0\xa7\x25\x56\x4a\xac\xd4\x14\xb5\x9e\x08\x9e\xd0\xd0\x08\x7d\x79\xde\x72\x8f\x4c\x46\x6e\xf0\x34\xf6\xc9\x51\x9c\x5f\x3f\x7f\xbc\xf6\x55\xbe\xb3\xb0\x4d\xe6\x55\x62\xe4\x28\x58\x4d\xa4\x16\xa5\xcf\x8e\x4a\x05\x9e\x8b\xd6\xe7\x12\x8c\x72\x39\x8e\x50\x49\x71\x5e\xc3\xb9\x00\x23\xc2\xa4\x5d\x51\x93\x41\xef\x2e\x21\x37\x12\xfb\xb8\x4d\x63\x2a\x70\x56\x2e\x93\x38\xa6\xc4\xbf\x49\x70\x94\xeb\x34\x3e\x1c\xda\x68\x41\x0f\x97\x18\xe2\x44\x66\x24\x55\x10\x96\x64\x88\xb7\xca\x34\xcc\xba\x38\x05\xe3\x39\x5d\x20\x86\x2a\x38\xe5\xbf\x91\x55\x05\xf8\xf8\x98\x2b\x29\x79\x6e\xc6\x9b\x93\xe7\x98\x0d\xb2\x7c\x83\xd6\xf8\xda\x13\xc0\xc2\x37\xea\xef\x97\xfb\xc3\xed\xe2\xce\x36\xec\x52\xb5\xf5\x1e\x1f\x0d\xc4\xe1\x30\xeb\xe4\x3c\xe8\x88\xd5\x06\x7b\xf2\x3c\x1f\xda\x72\xce\x49\x75\x63\x15\x24\xac\x8c\x56\x43\xf7\xdb\x07\x83\xf6\xc4\xc9\x07\xca\xa4\x9b\xa0\xb2\xec\xf4\xde\x94\x69\x98\xac\x53\x93\x6d\x73\x8c\x79\x6f\x13\x7f\x91\x7d\x08\xbd\x49\xbe\x21\x9c\x50\x3c\x88\x9f\x2c\x26\x33\xd3\x8d\xe3\x9a\xa9\xf0\x26\xaf\xc2\x89\xc3\xa8\x58\x54\x71\x86\x05\x6b\x10\x05\x3c\xd8\x13\x8d\x57\x8c\x32\x77\x82\xec\x28\x7f\xbd\x0f\x46\xf2\x08\x46\xf3\x08\x3a\x78\x04\x0e\x8f\x60\x76\x5a\x4f\xc8\x1a\xf7\xf7\xbc\xc8\x0e\xb5\xd4\xd5\x4b\x09\x39\x49\x86\xb3\x68\xca\xe9\x0a\xc4\x15\xdc\x03\xbf\x34\x8e\x4e\x51\x19\xb0\xd2\xee\x0d\x6e\x86\x5d\x70\xa6\x3f\x33\x28\x25\xa2\xe3\x5f\xb4\x14\x13\xda\xde\x61\x51\xe0\x1f\x2d\x80\xff\x1b\x64\x87\xc6\x06\x61\x56\x97\x72\x4c\x8a\xd5\x51\x37\xc2\x72\x76\x4c\xea\x6b\xa2\xb3\x74\xaa\x2d\xc6\xc4\x7a\x5b\x55\xd9\x1d\x06\x58\xd0\x1d\xaa\xb7\x4d\x22\x89\x87\x1e\x84\x09\x8b\xd0\xbc\x9f\xd6\x53\x66\x07\x41\xfb\xbb\xd3\x96\x15\xaa\xe2\x05\x95\x7d\xb2\xbc\x2a\xbb\x66\x6d\xcf\xaa\xaf\x2d\xcd\x17\x63\xdc\x82\xd1\x6e\x79\xb8\xcc\x04\x5b\xc4\xd4\x77\x1d\xb7\x55\x50\xa5\xf0\x96\xdd\x9d\x76\x9c\x3d\xe5\x08\x89\xb2\x13\x89\xd3\x3a\x1b\x40\x08\x9e\x46\xc8\xab\x67\xf6\x34\x0b\x57\x73\x26\x6f\x27\x78\x75\xb7\xfe\x79\xfb\x08\x0e\xdf\xe2\x7f\xe8\x9c\x93\x27\xb4\x0b\x4d\x16\x27\x14\x24\x3d\x31\xe0\x58\x4b\x43\xba\x71\xd4\x8e\xdb\x49\x9c\xe1\xb4\x2e\x0a\xa7\xbb\x9a\x88\x14\xc3\xce\x9d\x6c\xf4\x14\xcc\x13\x82\x86\x26\xc1\x3f\x25\x27\x69\x30\x40\x2e\x70\xc8\x05\x0e\x48\x43\xfc\x35\x8b\xe1\xca\xa4\xda\x3d\x01\x75\xae\xca\xb0\x18\xe4\x1d\xd5\xe0\x69\xf2\x23\x09\xf0\x70\xd8\x60\x27\x41\xa7\x52\x90\xd0\xd7\xc9\x55\xb2\xa6\x1c\x8a\x1b\xb8\x3b\x7e\x5d\xf4\x61\xbf\x63\x12\x2e\xf1\x06\xa3\xba\xf3\xa4\x2e\xb2\x4b\x4e\x95\xba\xa6\x31\x78\x13\x6b\x7e\x62\x58\xbb\x99\xc9\xd7\x99\x81\x63\x08\xb2\x38\xc5\x7f\x67\x2e\xb2\xcf\x41\x44\x7a\x8b\xed\x2f\x5e\x34\x43\x73\xcc\x70\xd5\xf9\x4c\x08\x90\x37\xf6\x90\x51\xe5\xc6\x6b\x18\x21\xbb\x73\x8d\x91\xad\x52\x4c\xc5\xc4\x94\x71\xc1\xa8\x96\xe7\x8e\x30\xa9\xb8\xa7\x6a\xa8\x1e\x9c\xf8\xcc\xcd\x3c\x2f\x0d\x37\x50\xf3\xd9\xcf\xc5\xea\x94\x4f\xdb\x5f\x13\x46\xab\x54\xbd\x1a\xd9\x98\x46\x17\xa1\xad\x14\x8d\x3b\x30\x0e\xd1\x7d\xdd\x1d\xc0\x2c\x4f\x77\x31\x65\x78\xdf\x63\xe2\x5c\xed\x60\xad\x7f\xa7\xd8\x6a\xea\xd0\x5c\xd5\xea\xde\xd5\x76\xb9\x4a\xd7\x5f\x41\xe7\xbb\xee\xfb\x1c\xf1\xa6\x48\xd5\xe1\xf0\x0d\x21\xd4\x4e\x74\x16\xd2\xee\x7d\x4f\x26\xc2\x95\xab\x63\x7d\x1c\x51\x0c\x1d\x84\x99\x35\xe9\xd7\x37\xcf\xda\x11\xa5\x1e\x47\x6d\x29\xbb\x53\xdd\x12\x86\x79\x8d\x5e\xab\xf4\x70\xa1\x9d\xbd\x2c\x3f\x41\x3f\x3b\x73\x3f\x46\xd7\x76\xb3\xae\x8f\x28\xd8\xf9\xff\xf7\x93\x7f\xf1\xfb\xc9\xfe\x7c\xcf\xd4\x87\x77\x78\xb3\x5e\xb1\x28\xc2\xfb\x9b\xde\x52\xd1\xb8\x32\x67\x95\x8d\xbd\xe3\x4d\xfe\x0b\x9f\x60\x72\x88\xf9\x53\xd7\xfb\xaa\xee\xff\x06\xd4\x1a\xd0\x19\x83\x19\x00\x00")
 
 func chartjsHtmlBytes() ([]byte, error) {
 	return bindataRead(
@@ -128,7 +128,7 @@ func chartjsHtml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "chartjs.html", size: 5536, mode: os.FileMode(420), modTime: time.Unix(1487560932, 0)}
+	info := bindataFileInfo{name: "chartjs.html", size: 6531, mode: os.FileMode(420), modTime: time.Unix(1792318778, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -173,7 +173,7 @@ func headerHtml() (*asset, error) {
 	return a, nil
 }
 
-var _revelHtml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xec\x57\xcd\x6e\xe3\x36\x10\xbe\xf7\x29\x06\x6a\x7a\x2b\x57\x36\x8a\xa2\x80\x23\xfb\xd0\x45\x7b\xc9\xa2\xdb\xc3\xde\x0b\x4a\x1c\x5b\x6c\x28\x4a\x20\x29\x27\xaa\xe0\x77\x2f\x48\x4b\x8e\xa4\x58\x7f\x8e\x1b\x24\x68\x7c\x50\x22\x71\xf8\xcd\x2f\xbf\x19\x06\x8c\xef\x21\x12\x54\xeb\xb5\x17\xa5\xd2\xa0\x34\xe4\x41\xd1\x2c\x43\xe5\x81\x36\x85\xc0\xb5\x97\x70\x49\x62\xe4\xbb\xd8\xac\x60\xb9\x58\x64\x8f\xb7\x50\xbf\xd2\xdc\xa4\xb7\x90\xee\x51\x6d\x45\xfa\xb0\x82\x98\x33\x86\xd2\xdb\x7c\x07\x00\xd0\xc6\x16\x24\x61\xe4\xa7\x6a\xc9\x2d\x6b\x8c\x0c\x4f\x65\x57\x7d\x8c\x94\xa1\x6a\x08\x76\xb1\xc2\xf4\xb1\xb3\x7a\x46\x82\x84\x29\x2b\xce\x88\x9d\x44\x39\x5b\x7b\x11\x95\x7b\xaa\x49\x9c\x0a\xd6\xf0\xf7\x81\x33\x13\xaf\x96\x8b\xc5\x0f\x3d\xfb\x1d\xc6\x71\xef\x11\x26\xa6\xca\x10\xaa\x90\x92\x85\x07\x7e\x8f\x52\x9f\xf1\xfd\x19\xb3\x9f\x7f\xee\x7c\x0a\xfc\x2a\x50\x55\x54\x9f\x56\xdf\x59\x80\xc9\xf2\x2a\x21\x5e\x7e\x84\xd8\x05\x07\xa5\x41\xb5\x09\xb4\x51\xa9\xdc\x6d\x04\x4a\x10\xb8\x47\x01\x51\x9a\x4b\x13\xf8\xd5\xf7\xc0\xaf\x05\x43\x35\x90\xac\x4a\xa9\xa4\x7b\x62\x68\xa8\x49\x94\x6b\x93\x26\x43\xc9\xc9\x85\x4b\x8c\x40\x49\x64\x9e\x38\x8f\xbd\x06\x0c\xd4\x50\x03\x18\xf6\x57\x96\x8a\xca\x1d\xc2\x8d\x29\x32\xfc\x11\x6e\x50\x1a\xc5\x51\xc3\x6a\x0d\x9f\xbe\xa0\xfc\x62\x5d\xfa\x6c\x3d\x3a\x1c\x06\x71\x02\xc1\xa1\x2c\xf9\x16\xb8\xfe\x9d\x2b\x6d\x0e\x87\xda\x18\x1a\x19\xbe\x47\x0f\xca\x12\x25\x3b\x1c\x36\x01\x85\x58\xe1\x76\xed\x7d\x1f\x52\xb5\x20\x65\xe9\x54\x1f\x0e\x1e\x30\x6a\x28\x31\xe9\x6e\x67\x4b\xd4\xd0\xd0\xdb\x9c\x16\x03\x9f\x6e\x02\x5f\xf0\x31\x67\x9c\x0a\x28\xcb\x1b\x99\x0b\x01\xab\x75\x24\x90\xaa\xca\xa0\xfe\x58\xfa\xb9\x18\x88\x74\x23\x3f\x86\x86\xa4\xaa\x31\xc8\xa8\x44\x31\x54\x22\x57\x0f\x71\x7d\xaa\xbb\x71\x6b\x18\x67\x8d\xea\xe4\xe1\x18\xff\x2a\x34\x23\xa6\x3e\x57\x63\xbd\xa5\x5c\xa2\x6a\x2a\x9c\xc3\x22\x2d\xe8\x06\xa3\x38\x74\x8a\x8a\x36\x81\x7b\x88\xa5\x85\x71\x9e\x64\x66\x8a\x5c\x5c\x29\xbd\xc8\x1f\xe4\x67\xc9\x2f\xc1\x04\x72\x8d\xec\x55\xf9\x2f\xc1\x77\xcb\x7f\xcb\x0f\xfe\xbb\x88\xff\x96\xaf\xc3\x7f\xcb\xff\x94\xff\x96\x1f\xfc\x37\x97\xff\x7e\x79\x93\xfc\x67\xd2\xcc\xde\xcc\x40\x50\xb5\x43\x6d\xe0\x1e\x0b\x3d\x87\x01\x0d\x0d\x05\x36\xca\x58\x20\xb8\xa7\xad\x3e\x86\x52\x23\xab\xde\x63\x7b\xd5\x03\x9d\x2a\xf7\xfa\x54\x88\xa9\x62\x24\x54\x48\xef\x57\xee\x49\xa8\x10\xb7\xe0\xbe\xda\xcb\x64\xe3\xe3\x10\xa3\x1a\x1b\xb8\x91\xca\x32\x3d\x2e\xb4\x85\x58\xed\x8b\xb5\xd4\x99\xfa\x17\x15\x59\x4c\xbd\x0d\xdc\x61\x01\x81\x6f\x46\xf4\x8c\xc2\x7c\x2b\x32\xbc\x06\xce\xaf\x85\x41\xfd\x12\x20\x99\x27\xa8\x78\xe4\x6d\xe0\x8f\x3c\xf9\xba\xfd\x4d\x60\x62\xc9\x71\x1c\x31\xf0\x87\x62\x19\xf8\x23\xd9\x08\x8c\xad\xcf\x89\xc4\x6b\x19\xb7\x38\xf2\xed\xb1\x42\xef\xb0\xd0\x63\x6c\x3b\x31\xd5\xb6\x47\x39\xfc\x4f\x77\x58\xd8\x46\x35\x31\x94\x4f\xfb\xbe\x55\x1d\x6e\xfa\xc6\x38\x4f\xa8\xe4\xff\xe0\x31\x7b\x15\x8c\x7b\xb9\x0c\xe7\x73\x9a\x24\xb4\xc6\x39\xe5\x71\x0a\xd6\x70\x16\xe1\x89\x69\x87\x32\xdd\x9f\xc9\xc0\x77\x55\x76\x3d\x5a\xed\xe3\xd5\x9f\xdf\x24\xaf\xba\xab\x34\x84\x85\x25\x54\xc8\x14\x6e\xf9\xe3\xab\x0c\x96\x67\xc6\x48\xf7\xcf\xdf\xb9\x36\x7c\xcb\x91\xbd\x64\xe2\x39\x9d\xc0\x3f\x9d\x43\x38\x7a\x10\x2f\x98\x2c\xad\xde\xff\xfb\x64\x39\x3b\xce\xf5\xdc\xd7\x0d\xde\x95\xc7\xcb\x37\xd1\xec\xdb\x16\x8d\x37\xfe\xb6\xfc\x84\xce\xd0\xde\x30\x3c\x10\x1c\x13\x34\xb1\x0b\x4f\x06\x9e\xd3\xda\x47\x41\x5b\x6d\x3e\x44\xf5\x75\x3b\x7d\x90\x39\x01\x8f\xf6\x8a\x86\xe4\xf4\x94\x4c\x99\x04\x9a\xbf\xe7\x53\x41\x7d\x6e\x46\x0e\x48\x5b\xe9\xfc\x1a\xb8\x68\x52\x78\x8e\xf1\xf2\xe6\xdf\x8f\xd9\x1d\x04\xe6\x22\x4e\x4f\x31\x4c\x18\x0d\x3a\xc8\xd3\xd2\xdc\x3b\x32\xb4\x85\xde\xff\x0d\xf1\xf8\xe7\xdf\x00\x00\x00\xff\xff\x8c\xd4\xc8\x9c\x41\x1b\x00\x00")
+var _revelHtml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xed\x5d\x6d\x6f\xdb\xba\x15\xfe\xbe\x5f\x41\x68\xed\x90\x00\x91\x1d\xb7\xf7\x62\x40\x9a\x78\x40\x93\x76\x2b\xd6\x37\xdc\xa6\xdb\xc7\x81\xb2\x68\x9b\x8b\xde\x2e\x45\x25\xf1\x0c\xff\xf7\x9d\x43\x52\xb6\x6c\xcb\xb6\x24\x2b\xbe\x52\xe2\x02\x6d\x6d\x89\x3a\x7c\x39\x87\xcf\x79\xce\x21\x45\x5f\xba\xfc\x9e\x0c\x3c\x1a\xc7\x57\xd6\x20\x0c\x24\x0b\xa4\xfd\x20\x68\x14\x31\x61\x91\x58\x4e\x3c\x76\x65\xf9\x3c\xb0\xc7\x8c\x8f\xc6\xf2\x82\xf4\xce\xcf\xa3\xc7\x77\x24\xfd\x4a\x13\x19\xbe\x23\xe1\x3d\x13\x43\x2f\x7c\xb8\x20\x63\xee\xba\x2c\xb0\xfa\x7f\x22\xf0\x67\x3a\xe5\x43\xd2\xf9\x20\x44\x28\xe2\xd9\x4c\x5d\xba\x5c\xaa\xce\xb3\x7d\xd7\xee\xbd\x31\xc5\xd5\xfd\x98\x0d\x24\x0f\x83\xd5\x26\x8d\x19\x75\xa1\x45\x8b\x82\xab\xc2\x9c\xf0\x91\xc0\x5f\xdb\xa5\xc1\x68\xad\x60\x4e\x61\xdb\x09\xdd\x49\x4e\x31\x55\x74\x00\x55\x32\xd1\xbf\x8c\xa5\x08\x83\x51\x7f\x3a\xd5\x9d\xf8\x9a\xf8\xb3\x19\x71\xd9\x20\x74\x79\x30\x22\x4c\x75\xec\x8c\xc8\x31\x83\x91\xa2\x92\xc7\x92\x0f\x62\x42\x05\x23\x11\x15\x92\x53\xef\xb2\x6b\x24\x5c\x76\x53\x91\x8e\xd8\x50\xa7\xa4\x8e\xc7\xd2\x06\xea\x2f\xea\x5f\x1b\x86\x00\x86\x34\x66\xae\xf9\x3e\xc6\xd1\x26\x71\x28\xd4\xd7\xb9\x92\x1e\x42\xe1\xda\x8e\x60\xf4\xee\x42\xfd\x6b\x53\xcf\x7b\x47\xd4\x55\xd4\x67\xe6\xe2\x86\x5e\xeb\x56\xe0\x40\x6f\xbe\xaf\xcb\x88\xed\x05\x74\x21\x37\xed\x0b\xb6\x54\x35\xf5\x3f\xd4\x8b\xc6\xd4\xea\x93\x7f\xb2\x09\xb9\xec\x4a\xb7\xa2\x98\x20\xf1\x99\xe0\x03\x10\x74\xf3\xbe\x1e\x39\xdf\x86\xc3\x98\xc9\x7a\x64\xfd\xb8\xe3\x30\x79\xdc\xf7\x13\xc9\xe2\x7d\x24\xa6\x83\xa5\x2c\xaf\x80\x20\x28\xb1\x45\x2f\x70\x77\xbb\x66\x2f\x25\x4e\x88\xed\x55\x4c\xa7\x02\x67\x17\x79\x05\x96\x4f\x2e\xae\x56\xa6\xf6\xbe\xf6\x02\xd3\x0c\x05\x77\xc0\x3a\x66\xb3\xc2\x03\x97\x3e\x75\xf3\xbe\xdc\x43\x80\x4c\xa6\x27\x1d\xa3\xfd\xf3\xd9\xcc\xc8\xd2\x17\xf0\x2b\x0b\xdc\x0a\x6d\x31\x26\x50\xe1\x49\x35\xa0\x45\x9e\xdb\xae\x6d\xad\x2c\xd5\xf8\x6d\x16\xb1\x59\xe3\x70\x13\x8d\x30\x07\x45\xbb\x00\xa3\x2b\x28\xbc\x7c\x09\x30\x4f\x43\xb8\xbe\x94\xb9\x9b\x6d\xd1\x74\xfa\xc0\xe5\x98\x74\xae\xa9\xc7\x1d\x41\xb1\xfc\x01\x3c\x44\xbd\x6e\x61\xb0\x68\x3b\xa1\x23\xca\x83\x58\x92\x04\x70\xda\xf6\x99\xff\x74\xc8\x5f\x37\xe0\xef\x9e\xf6\x38\x81\x11\xa2\xd2\x7a\xb9\x2b\xc7\x17\xbd\x5f\x5f\x5b\xfd\x45\x6f\xc1\x5e\xb5\x19\x8f\x13\x9f\x06\xfc\x7f\xec\x07\x1f\x05\x29\x0a\x76\x7e\x42\xb9\x2f\xcc\x37\x86\xbd\xdb\x76\x4d\x8d\xfd\x3b\x36\x89\x73\x64\x1b\xa9\x1f\xc0\xdf\xfa\x54\x9a\x5a\x4a\x0b\xf7\x41\x63\xc4\xe5\x03\xb9\xb9\x86\x2f\x50\xe4\x06\x4a\x54\xab\x80\x3d\x46\x5c\x80\x98\xed\x75\x7c\xd0\xa5\xaa\x57\x93\x04\x50\x91\x07\x2d\x65\xee\x4e\x3d\x2c\x8a\x9a\xba\xc8\x89\x60\x91\xc7\x07\xda\x8a\x1d\x3a\xb8\xf3\xc2\xd1\x19\x18\x23\x07\xab\x25\x4e\x32\x1c\x32\xa4\x38\x5e\x42\xcf\x90\xe3\x08\x99\x44\x04\x14\x1e\x8a\x09\xa1\x81\x4b\xfc\xd0\x65\x9e\x21\x42\xa7\x65\x87\x27\x55\x1f\xe9\x92\x1c\x43\x8a\x04\x0f\xe4\x90\x58\xaf\x3b\x6f\x86\x16\xe9\xfc\x86\x2d\x2c\x3c\x36\x0a\xdf\x03\x46\x3a\x3f\x60\x92\x32\xd2\xeb\x00\xb8\x67\x8d\x8a\xc4\x78\xdd\x25\xce\x24\xb7\xc6\xb7\x58\xa3\x7a\x34\x5b\x63\xd3\x11\x15\xba\x0c\x4c\xa1\xf3\x85\x49\xea\x52\x49\xf1\x13\x2a\xea\x0b\xea\xa8\x75\xe0\xea\x9b\x5e\x3c\x27\x20\xd5\xf1\xd0\xba\x56\xf6\x66\xda\xeb\xc8\x6c\xe6\xa8\x9a\x9f\x65\x58\xc8\x72\xeb\x0e\xc1\x43\x96\x88\x25\x4d\x1e\x15\xb1\x4c\x6d\xf8\xe9\x06\x08\x08\x17\x54\x56\x9e\x6c\x82\x06\xd9\xef\x24\x7d\x96\x58\x80\x8c\x16\x48\x88\x04\x4b\x45\xfe\x8b\x7a\x89\xc2\x0d\x7d\x8d\x79\x31\x53\xcc\x32\x73\xaf\x30\xb1\x6c\x3f\xcd\x43\x8b\xbf\x79\x1f\xb7\x0e\x7f\xd0\xfa\x1c\x1a\xb3\xf8\x18\xc3\x3f\x49\xf0\x9d\x86\xb7\xb5\xc5\xc9\x30\x1b\xe3\x88\x0e\xd8\x37\x18\x59\x1c\x80\x7a\xa2\xf9\xaf\x89\xef\x30\xf1\x6d\x58\x2c\x65\x71\x80\x18\x1c\x34\x47\xe6\x28\xd4\xb9\x4e\x84\x00\xa3\x04\x55\xd8\xbd\xd9\xcc\xf4\x83\xc2\x14\xba\x07\xa3\x32\x13\xb1\x18\xb2\x5d\x52\x32\x16\x6c\x78\x65\x75\x31\x8e\xa1\xc1\x80\x75\x01\xb3\xd2\x0a\x3e\x99\x6b\xb3\x99\xd5\x07\xf3\xbb\xec\xd2\x7e\x71\xd0\xfc\x63\x4a\x16\x81\x4e\xe3\x75\x5c\x47\x39\x9d\x05\x4c\x95\x1d\x7c\x90\xa0\x12\x10\xfb\x28\x40\xc9\x7d\xd5\xf9\xce\x84\x4a\x65\x14\x54\xc7\xdf\x5c\xe7\x0a\x6e\x98\xfa\xad\xbe\xeb\x2c\xbe\xa1\x96\x52\xff\x93\xbd\x5e\x21\xad\xb1\x1c\xb3\xa0\xa0\xdb\x50\x7a\x2c\x1b\xb0\x54\x96\xb4\x3a\x71\xab\xc9\xbb\x0e\x7d\x9f\x2e\x5a\xa6\x52\xb5\xcf\xdf\xbf\xe6\xf8\xd3\xb7\xcd\x70\xa7\x58\x94\xbb\x50\x25\x0d\xee\x69\x0c\x0e\xcf\x73\xb3\x94\x5b\x13\xb1\xf3\xf3\xd7\xdb\x1c\x99\x7e\x56\x8b\x19\x43\xec\x69\x53\xf0\x7f\xf6\xb9\x45\xba\x9b\x86\x7b\x6d\x54\xf7\x1d\xec\x96\x0d\xb0\xdd\xab\x65\x88\x7b\xc7\x21\xce\xa1\x84\x1e\x0b\x88\xc7\xee\x99\x47\x06\x61\x12\xc8\x32\xc4\x30\x53\x69\x40\xef\x6d\x40\x85\xd8\x1e\x24\xb1\x0c\xfd\x6d\xca\x49\x3c\xa5\x18\xa8\xd7\x06\x46\xa2\x7a\x6c\x65\xc4\x90\x54\x94\x55\xd0\xcf\xc9\x49\xc4\xce\xc8\x2b\x68\xac\xe0\x00\xbe\xe8\xf3\x3e\xb3\xe0\x33\x76\xe9\x1a\x7b\xb4\xcb\xfb\x79\x5c\x7b\x3f\x1e\x7f\xe4\x22\x86\xe2\x64\x83\xc3\x9b\x7b\xaf\x3f\x3b\x54\x9c\xdb\xe0\x7a\xb0\x6a\xf0\x4f\x04\x69\xb5\x2d\xc3\xd1\x08\x4d\x14\xda\xae\x02\x31\x7d\x53\x93\x0a\x8f\x17\xc2\x63\xf8\xff\x55\x90\x78\x1e\x74\x62\xe0\x31\x2a\x4c\x83\xb6\xc0\x74\xe2\x6d\x19\xe9\x8c\x7e\xa0\x51\xb6\xb1\x31\x12\xd1\x80\x79\xdb\x4c\xa4\xf6\x21\x4e\x67\xf5\xea\xb8\x65\x1a\x87\x8d\x5a\xd1\x83\x1e\x7f\x33\x34\x56\x01\xd7\xb9\x54\x0d\xf6\x16\x73\x82\x22\x5b\x61\x19\x14\xd9\x84\x28\x4a\x3a\x65\x82\x66\x05\x77\x0b\x34\x2f\x1f\x64\x4a\x16\xa9\x6c\x29\x1b\x25\x1f\xc1\x0f\xc1\xcf\x67\xbe\xca\xd7\x1e\x14\xff\xa0\xd2\xb6\xe2\x5f\xef\x88\x7f\x95\xf0\xaf\x77\x18\xfc\xeb\x3d\x29\xfe\xf5\x8e\xf8\x57\x16\xff\xfe\xda\x48\xfc\x93\x61\x84\x7b\x9f\x88\x47\xc5\x88\xc5\x92\xe8\xa5\xc9\x63\x6a\xb0\xfe\xed\x3d\xa9\x98\x5b\x98\x30\x8d\xc8\x30\x2e\x65\x03\xbf\x0d\x3f\x78\xcc\x47\x70\x6c\xd8\x96\x1c\x40\xdc\x89\xc6\x5b\x6d\xa1\x98\x56\xa9\x73\x67\x0e\xca\xaf\xb2\x37\x47\x3d\x77\x6b\x3c\x5c\xe5\x3c\x91\x16\xb3\x47\xbe\xc9\xe4\x87\xb4\x9c\xb9\x1e\x9f\x63\x8e\x68\x13\xae\xfe\xda\xcc\x4d\x34\xc8\x0a\x88\x33\x41\x40\x25\x11\x90\x26\xfe\x78\x10\x62\x99\x43\x23\xd5\x87\xff\xc2\xc3\x7c\xc8\x99\xbb\x0f\xe3\x99\xcf\xc0\xef\xaa\x43\x2c\x7e\x02\x66\x89\xf5\xbe\x74\x66\x59\x7a\x9c\x53\xde\xb7\x3a\x78\x35\xd3\xcb\x46\x38\xfb\xb2\x8e\xbf\xb4\x67\x28\x43\x08\xb4\x82\x0a\x7a\xe1\x27\x71\xed\x4f\xb6\xe8\x57\xce\x57\x14\xf6\xfe\x65\x99\xc0\x76\x56\x90\xce\x9b\x1d\x13\x64\x5f\x1b\xa8\xc4\x14\xca\x39\x7f\xbd\x4e\x96\xb9\x64\xf6\xcc\x92\x13\x7b\xdb\x83\xa6\xd4\x69\xa9\xc5\xaf\xe2\x74\x62\xb9\x5d\x70\x21\xaf\x55\xab\x0f\xed\xd5\xa6\xe2\xa6\x56\x74\x17\x4e\x21\xba\x52\x88\xba\x3c\xb7\x48\x35\xb3\x97\xe5\x1f\xa1\x94\xcb\xec\xba\x75\x21\xec\x58\x77\x41\x85\xb0\xe4\xe4\xf3\xc7\x9f\x7a\x3d\x83\x89\xd3\x63\x40\xdb\xe4\x80\x76\xe1\xa0\x50\x67\x07\x8c\x8c\xff\x98\x38\x76\x7d\xa6\xb5\x31\x8e\x35\x0f\x7e\x14\xec\xf7\x43\x06\xc0\x6d\xde\xd8\x90\x41\xdb\x4f\xae\xc7\xd6\x92\xc7\xad\x89\x6d\xb9\x8b\xa0\xc8\x7d\xb6\x1c\xdf\x02\xe6\xfe\xf6\xf3\xb4\x3d\x1b\x9b\xeb\x83\xd8\xb2\x18\xd8\x27\x68\x00\x7f\x19\xc9\x77\xd3\x69\xe7\x3a\xf4\xdc\x1b\x8a\x60\xe0\x96\xcd\xe9\x6d\x94\xd3\xb8\xed\x7d\xab\xe1\xae\xa3\x3a\x8a\x70\x88\xcd\x36\xd3\xbf\x36\x30\x94\xfb\xa6\xe5\x9c\x7d\x13\x72\x27\x1c\x0c\xf8\x11\x37\xd3\x41\xf7\x40\x1b\xba\xe3\xa7\x4d\x86\xb8\x67\x36\x23\xcb\x06\xe7\xf3\xe9\x54\xa6\x7c\xe9\xf9\xda\xdc\x89\xa9\x31\x7c\x25\x13\x95\xeb\xa7\x6a\x8f\xce\xcb\x4c\x6d\xdd\xcc\x32\x53\x53\xc3\xa3\x21\x1a\xaa\x33\xcd\xca\xd8\x1f\xf2\x75\x94\x16\x50\xa3\xdb\xdb\xcf\xed\x65\x46\xd0\xf8\xea\x39\xff\x97\xcb\x86\xbe\x86\x38\x70\xe5\xc1\x54\x3d\x56\x3e\xab\xa9\x1e\xd6\xef\xa7\x56\xa0\x5c\xe9\x83\x8d\xe7\x58\x10\x57\x2b\x10\x47\x6d\x28\x9c\x69\x0c\xbf\x9a\xb3\x23\xa5\x41\x03\xac\x85\x09\xd2\x4e\xce\xa5\xa4\x96\x23\x5d\x3b\x5b\x6a\xb4\x5e\x7b\x5b\x8d\xdc\xf6\x50\xc4\x0c\x4a\xd3\xbb\x4d\x9e\x3d\x0f\x17\x3d\x2c\x7f\x41\xfc\x30\x4d\xd1\xe9\xf7\xc8\x89\x93\x48\x12\x87\x10\x4c\x06\x0c\x13\x6a\x6e\x78\xcc\xd6\xd5\xb2\xda\x54\xfb\x62\xd0\x2e\x81\xd5\xd1\x78\x73\xa7\xcb\x79\x86\x43\x82\x2c\x9a\xb3\x86\xd7\xed\x53\xa1\x0a\xbe\xa2\xec\xce\xf7\xd2\x4c\x77\x8d\x63\x2a\x39\x05\x29\xe6\x0e\x21\x06\x52\xf7\xa2\xce\x0b\x49\x8d\xcf\xf7\xed\x92\xfc\xd2\x03\x65\x9c\x95\xc7\x38\x79\x1e\x27\xe7\x05\x2d\x2d\x0b\x93\xd3\x73\x36\x4e\xf0\x1d\xd0\x4c\xb0\x4c\x2c\xed\xa7\x5d\xeb\x34\xe7\x5e\x10\x06\xcc\x3a\xd5\x27\x4c\x65\x03\xec\xf4\xfd\xcc\xbc\xe0\x7b\xff\xf7\x34\x8f\x31\xf8\xd3\xc6\xe0\xff\x1e\x53\xf9\x69\xb8\xb2\xf9\xa8\x3d\x67\x1c\x3c\x40\xf3\x6d\xb4\xe6\x21\x01\xbb\xe5\x71\x07\x9a\x32\x3c\xd3\xa4\x53\xd2\x3b\xdc\x06\xb5\x72\x58\x91\xee\x70\x7a\x76\x90\x3a\xc2\x0b\x4f\x3f\xc4\xe7\xf8\xe8\xc8\x47\x9f\x8e\x8f\x36\x6e\x53\x74\x2a\x28\x63\x11\x35\x9d\xe9\xc8\x3c\x49\x9f\xe9\xe1\x0d\x39\xab\xdc\xb9\x10\xd2\xc6\x85\xee\xba\x5d\xcf\x92\x9c\x25\xdc\x29\x6e\x1b\x0b\xa3\xd0\x7b\x82\x01\xd9\xae\xac\x79\x1f\x95\xa5\xe1\x86\xce\xfc\xd3\xd2\x96\x4b\xb5\xc7\x7b\x36\xc2\x33\xfe\x5d\x84\x49\xd4\x3e\x8f\xa8\x5c\x9f\x33\x21\x92\x51\x1f\xdd\x22\x3a\x37\x91\x78\xc7\x53\x80\x36\xb9\x23\x1c\xa7\x5a\xb0\xba\xde\xb7\x74\xea\x4c\xad\x64\xde\xa7\x69\xac\x17\x41\x3d\xd4\xef\x3c\x50\x6a\x7d\x70\x6b\xd0\xdb\x6a\x48\x98\xb2\x90\x62\x46\x93\x43\xac\x8a\x0b\x52\x69\xa0\x39\x59\x7e\x93\x4a\xe1\x9a\x27\xa1\x1c\x79\xab\xe2\x38\x28\xab\xdd\x2b\x39\x59\xeb\x11\xde\x33\xfd\x39\x45\xa0\x30\x08\xd9\xf8\x73\xdf\xb6\x61\xe2\x08\x21\xfd\x08\x8a\xbb\x01\x43\xf9\xbe\x5a\xc8\xfe\x11\x5d\x1b\x81\xae\x4b\x64\xa6\x3e\x78\x55\x62\xab\xb1\xf3\x23\x30\xbf\x30\x60\xae\x99\x9e\x7f\x08\xf4\x6f\x58\xb4\x72\xf3\x08\x33\x8d\xd7\x4c\x1d\x13\x22\x78\x14\xf5\x71\x2f\x49\xb9\xbd\x24\xa9\x09\xbc\xd0\xe5\x89\x05\xba\xe7\xcd\x85\x1a\x39\x74\xd5\x37\x06\xd2\x66\xbd\xc4\x05\x80\xe3\xc6\xdc\xca\xeb\x8d\x47\x14\xd8\x7b\x91\x72\x3e\xf0\x65\x90\xa1\xb1\xab\x95\x47\x1c\x7a\x01\x0b\x91\x7a\x77\xdc\x2d\xf7\x99\xc7\x03\xd6\xce\xb4\xab\x5a\x3e\x47\x28\x8a\x20\xfe\xf7\x79\x90\x48\x46\x78\x40\xde\xfc\x42\xc6\x61\x22\x62\x42\x87\xf0\x88\xfe\xb1\xb5\x80\x46\xf1\x38\x2c\x7d\x02\x27\x9e\xfc\xa0\x17\xe9\x6d\x69\x86\x6a\x71\xf8\x57\xce\x91\x5f\xf3\x9f\xb9\x7b\xf3\xcb\x79\xf4\x58\xf0\x18\xd5\xd5\x0a\xf0\xfc\xaf\xb2\xc7\xa9\xae\x2a\xf6\x47\xc4\xef\x58\xd1\x3d\x84\xaa\x01\xfa\xf7\x5c\x62\xf5\xdc\x31\x4d\x93\x9f\x5d\xc1\x17\xf3\x6a\xc8\x60\xfc\x0c\xb8\x6c\x64\x6a\xa5\xce\xa3\xf5\xd3\x05\xca\x86\xf9\x6f\x65\xe0\x9a\xc5\xef\x9e\x28\x55\x4d\x27\x33\xa3\xd3\x63\x76\xe0\xa3\x4a\xa0\xa8\xfa\x3b\x68\x49\x3a\x7f\xb2\x74\xa1\x94\xb3\xd5\x0f\xa2\x2d\xed\xe5\x20\xb5\x98\x7d\x37\x20\x6a\x29\x15\xdc\x75\x4a\xab\x14\xff\xd1\x52\x16\x4b\xdb\x48\x60\x16\x89\x19\xf8\xac\x39\xc9\x19\x59\xeb\x45\xa4\x7b\x70\xb6\xb6\x01\x05\xee\xe4\x64\x6f\xda\xb9\x3d\xb2\x0e\x0a\x60\x2e\xfd\x1f\x59\x35\x3f\xc5\xc5\x75\x00\x00")
 
 func revelHtmlBytes() ([]byte, error) {
 	return bindataRead(
@@ -188,7 +188,7 @@ func revelHtml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "revel.html", size: 6977, mode: os.FileMode(420), modTime: time.Unix(1488434268, 0)}
+	info := bindataFileInfo{name: "revel.html", size: 30149, mode: os.FileMode(420), modTime: time.Unix(1792322777, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }